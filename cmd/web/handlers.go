@@ -2,12 +2,20 @@ package main
 
 import (
 	"errors"
-	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/eiliz/snippetbox/pkg/detect"
+	"github.com/eiliz/snippetbox/pkg/diff"
 	"github.com/eiliz/snippetbox/pkg/forms"
+	"github.com/eiliz/snippetbox/pkg/highlight"
 	"github.com/eiliz/snippetbox/pkg/models"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
@@ -20,31 +28,220 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, "home.page.tmpl", &templateData{Snippets: snippets})
 }
 
+// How many snippets are listed per page in the archive and search results
+const pageSize = 20
+
+// snippetArchive lists every unexpired snippet, paginated with the after and
+// before cursors
+func (app *application) snippetArchive(w http.ResponseWriter, r *http.Request) {
+	after, before, err := pageCursors(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.snippets.Page(after, before, pageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td := &templateData{Snippets: page.Snippets}
+	setPageLinks(td, r, page)
+
+	app.render(w, r, "archive.page.tmpl", td)
+}
+
+// search lists the unexpired snippets matching the q parameter, paginated like
+// the archive. Without a query only the search form is shown.
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	td := &templateData{Query: query, SearchTerms: models.SearchTerms(query)}
+	if query == "" {
+		app.render(w, r, "search.page.tmpl", td)
+		return
+	}
+
+	after, before, err := pageCursors(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.snippets.Search(query, after, before, pageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td.Snippets = page.Snippets
+	setPageLinks(td, r, page)
+
+	app.render(w, r, "search.page.tmpl", td)
+}
+
+// tagSnippets lists the unexpired snippets with the tag in the URL, paginated
+// like the archive
+func (app *application) tagSnippets(w http.ResponseWriter, r *http.Request) {
+	after, before, err := pageCursors(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Tags are stored in lowercase so /tag/Go lists the snippets tagged go
+	tag := strings.ToLower(r.URL.Query().Get(":name"))
+	page, err := app.snippets.Tagged(tag, after, before, pageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td := &templateData{Tag: tag, Snippets: page.Snippets}
+	setPageLinks(td, r, page)
+
+	app.render(w, r, "tag.page.tmpl", td)
+}
+
 func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	s := app.readSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	// The author reloading their own snippet isn't a view
+	if !app.canModify(r, s) {
+		app.views.add(s.ID, time.Now())
+	}
+
+	td := app.showPageData(w, r, s, forms.New(nil))
+	if td == nil {
+		return
+	}
+
+	app.render(w, r, "show.page.tmpl", td)
+}
 
-	if err != nil || id < 1 {
-		app.notFound(w)
+// snippetStats shows the author of a snippet its views of the last days
+func (app *application) snippetStats(w http.ResponseWriter, r *http.Request) {
+	s := app.getSnippet(w, r)
+	if s == nil {
 		return
 	}
 
-	s, err := app.snippets.Get(id)
+	if !app.canModify(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
 
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, 1-statsDays)
+
+	viewed, err := app.snippets.DailyViews(s.ID, since)
 	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
+		app.serverError(w, err)
+		return
+	}
+
+	byDay := map[string]int{}
+	for _, d := range viewed {
+		byDay[d.Day.Format("2006-01-02")] += d.Views
+	}
+	for _, vc := range app.addPendingViews(s) {
+		byDay[vc.Day.Format("2006-01-02")] += vc.Views
+	}
+
+	// Every day is listed, the ones without views too
+	days := make([]*models.DailyViews, 0, statsDays)
+	maxViews := 0
+	for d := since; !d.After(today); d = d.AddDate(0, 0, 1) {
+		n := byDay[d.Format("2006-01-02")]
+		days = append(days, &models.DailyViews{Day: d, Views: n})
+		if n > maxViews {
+			maxViews = n
+		}
+	}
+
+	app.render(w, r, "stats.page.tmpl", &templateData{Snippet: s, DailyViews: days, MaxViews: maxViews})
+}
+
+// rawSnippet serves the content of a snippet as plain text, e.g. for curl
+func (app *application) rawSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.readRawSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	// Browsers must not guess another type, an HTML snippet would run its
+	// scripts otherwise.
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.WriteString(w, s.Content)
+}
+
+// downloadSnippet serves the content of a snippet as a file attachment named
+// after its title and language
+func (app *application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.readRawSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": snippetFilename(s)})
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.WriteString(w, s.Content)
+}
+
+// unlockSnippet checks the password of a protected snippet and remembers in
+// the session that it was unlocked. Each session only gets a few attempts
+// per snippet, and all of them together a few more, so the passwords can't be
+// guessed.
+func (app *application) unlockSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.getSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	session, err := app.unlockSession(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !app.unlocks.take(s.ID, session, time.Now()) {
+		app.clientError(w, http.StatusTooManyRequests)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	err = app.snippets.CheckPassword(s.ID, form.Get("password"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.Errors.Add("generic", "Password is incorrect.")
+			app.render(w, r, "unlock.page.tmpl", &templateData{Form: form, Snippet: s})
 		} else {
 			app.serverError(w, err)
 		}
-
 		return
 	}
 
-	app.render(w, r, "show.page.tmpl", &templateData{Snippet: s})
+	app.unlocks.reset(s.ID, session)
+	app.session.Put(r, unlockedKey(s.ID), true)
+	http.Redirect(w, r, s.Path(), http.StatusSeeOther)
 }
 
 func (app *application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
-	app.render(w, r, "create.page.tmpl", &templateData{Form: forms.New(nil)})
+	// Snippets expire in a year unless the author picks something else
+	form := forms.New(url.Values{"expires": []string{"365"}})
+	app.render(w, r, "create.page.tmpl", &templateData{Form: form, Languages: highlight.Languages})
 }
 
 func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
@@ -56,23 +253,387 @@ func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
 
 	form := forms.New(r.PostForm)
 	form.Required("title", "content", "expires")
-	form.MaxLength("title", 100)
-	form.PermittedValues("expires", "365", "7", "1")
+	validateSnippet(form)
+	validateExpiry(form, expiresAfterFirstView)
+	// bcrypt doesn't hash passwords over 72 bytes
+	form.MaxBytes("password", 72)
+
+	// Tags are case insensitive, store them in lowercase
+	form.Set("tags", strings.ToLower(form.Get("tags")))
+	form.MaxItems("tags", maxTags)
+	form.Tags("tags")
+	// An empty language is plain text
+	form.PermittedValues("language", highlight.Names()...)
+	form.PermittedValues("visibility", models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate)
+	form.PermittedValues("format", models.FormatCode, models.FormatMarkdown)
+
+	parent, ok := app.forkedSnippet(w, r, form)
+	if !ok {
+		return
+	}
 
 	if !form.Valid() {
-		app.render(w, r, "create.page.tmpl", &templateData{Form: form})
+		app.render(w, r, "create.page.tmpl", &templateData{Form: form, Snippet: parent, Languages: highlight.Languages})
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Get("title"), form.Get("content"), form.Get("expires"))
+	s := &models.Snippet{
+		UserID:     app.authenticatedUserID(r),
+		Title:      form.Get("title"),
+		Content:    form.Get("content"),
+		Language:   form.Get("language"),
+		Visibility: form.Get("visibility"),
+		Format:     form.Get("format"),
+		Tags:       form.List("tags"),
+		Password:   form.Get("password"),
+		Expires:    expiryTime(form, time.Now().UTC()),
+	}
+	// Markdown isn't highlighted
+	if s.Format == models.FormatMarkdown {
+		s.Language = ""
+	}
+	detectLanguage(s)
+
+	if parent != nil {
+		s.ForkOf = parent.ID
+	}
+
+	if form.Get("expires") == expiresAfterFirstView {
+		s.BurnAfterReading = true
+		// The snippet is handed over by its link. Listing it would let anyone
+		// burn it before the recipient reads it.
+		if s.Visibility == "" || s.Visibility == models.VisibilityPublic {
+			s.Visibility = models.VisibilityUnlisted
+		}
+	}
+
+	s.ID, err = app.snippets.Insert(s)
 	if err != nil {
-		app.serverError(w, err)
+		// bcrypt refuses passwords over 72 bytes
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			form.Errors.Add("password", "This field's value is too long. It must have a maximum length of 72 bytes")
+			app.render(w, r, "create.page.tmpl", &templateData{Form: form, Snippet: parent, Languages: highlight.Languages})
+		} else {
+			app.serverError(w, err)
+		}
 		return
 	}
 
 	app.session.Put(r, "flash", "Snippet successfully created!")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, s.Path(), http.StatusSeeOther)
+}
+
+// forkSnippetForm opens the create form pre-filled with a copy of a snippet.
+// Forking reads the snippet like showing it does.
+func (app *application) forkSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s := app.readSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	form := forms.New(url.Values{
+		"title":    []string{s.Title},
+		"content":  []string{s.Content},
+		"language": []string{s.Language},
+		"format":   []string{s.Format},
+		"tags":     []string{strings.Join(s.Tags, ", ")},
+		"expires":  []string{"365"},
+		"fork_of":  []string{strconv.Itoa(s.ID)},
+	})
+	// The snippets opened by their slug need it to be forked
+	if slug := r.URL.Query().Get(":slug"); slug != "" {
+		form.Set("fork_slug", slug)
+	}
+
+	app.render(w, r, "create.page.tmpl", &templateData{Form: form, Snippet: s, Languages: highlight.Languages})
+}
+
+func (app *application) editSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s := app.getSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	if !app.canModify(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	// Pre-populate the form with the stored snippet. Leaving expires empty
+	// keeps the current expiry date.
+	form := forms.New(url.Values{
+		"title":   []string{s.Title},
+		"content": []string{s.Content},
+	})
+
+	app.render(w, r, "edit.page.tmpl", &templateData{Form: form, Snippet: s})
+}
+
+func (app *application) editSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.getSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	if !app.canModify(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("title", "content")
+	validateSnippet(form)
+	validateExpiry(form)
+
+	if !form.Valid() {
+		app.render(w, r, "edit.page.tmpl", &templateData{Form: form, Snippet: s})
+		return
+	}
+
+	s.Title, s.Content = form.Get("title"), form.Get("content")
+	// Without an expires option the snippet keeps its expiry date
+	if form.Get("expires") != "" {
+		s.Expires = expiryTime(form, time.Now().UTC())
+	}
+	detectLanguage(s)
+
+	err = app.snippets.Update(s, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, s.Path(), http.StatusSeeOther)
+}
+
+// extendSnippet pushes back the expiry of a snippet, with the same choices as
+// the create form
+func (app *application) extendSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.getSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	if !app.canModify(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("expires")
+	validateExpiry(form)
+
+	var expires time.Time
+	if form.Valid() {
+		// Extending never brings the expiry forward
+		expires = expiryTime(form, time.Now().UTC())
+		if !expires.IsZero() && (s.Expires.IsZero() || expires.Before(s.Expires)) {
+			form.Errors.Add("expires", "The snippet already expires later than that.")
+		}
+	}
+
+	if !form.Valid() {
+		if td := app.showPageData(w, r, s, form); td != nil {
+			app.render(w, r, "show.page.tmpl", td)
+		}
+		return
+	}
+
+	err = app.snippets.Extend(s.ID, expires)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet expiry extended!")
+
+	http.Redirect(w, r, s.Path(), http.StatusSeeOther)
+}
+
+func (app *application) deleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.getSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	if !app.canModify(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := app.snippets.Delete(s.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet successfully deleted!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	s := app.readSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "history.page.tmpl", &templateData{Snippet: s, Revisions: revisions})
+}
+
+// snippetDiff compares two versions of a snippet given by the from and to
+// query string parameters. By default the latest version is compared with the
+// one before it.
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	s := app.readSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	to, err := queryInt(r, "to", s.Version)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	from, err := queryInt(r, "from", to-1)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	toRev, ok := app.revision(w, s.ID, to)
+	if !ok {
+		return
+	}
+
+	// Comparing the first version with "version 0" shows the whole content
+	// as added.
+	fromRev := &models.Revision{SnippetID: s.ID}
+	if from != 0 {
+		if fromRev, ok = app.revision(w, s.ID, from); !ok {
+			return
+		}
+	}
+
+	app.render(w, r, "diff.page.tmpl", &templateData{
+		Snippet: s,
+		From:    fromRev,
+		To:      toRev,
+		Diff:    diff.Unified(fromRev.Content, toRev.Content, 3),
+	})
+}
+
+// detectLanguage guesses the language of a code snippet when the author didn't
+// pick one and clears the guess otherwise
+func detectLanguage(s *models.Snippet) {
+	s.DetectedLanguage, s.LanguageConfidence = "", 0
+	if s.Language == "" && s.Format != models.FormatMarkdown {
+		s.DetectedLanguage, s.LanguageConfidence = detect.Language(s.Title, s.Content)
+	}
+}
+
+// The maximum number of tags of a snippet
+const maxTags = 5
+
+// The number of passwords a session may try for a protected snippet within the
+// unlockWindow, and the number every session together may try
+const (
+	maxUnlockAttempts        = 5
+	maxSnippetUnlockAttempts = 50
+)
+
+// The expires options of the forms besides a number of days: after the first
+// view, never, after a custom duration and at a custom date
+const (
+	expiresAfterFirstView = "view"
+	expiresNever          = "never"
+	expiresIn             = "in"
+	expiresAt             = "at"
+)
+
+// How long the snippets deleted after their first view are kept when nobody
+// views them, and the longest custom duration
+const (
+	afterFirstViewExpiry = 7 * 24 * time.Hour
+	maxExpiry            = 10 * 365 * 24 * time.Hour
+)
+
+// validateSnippet runs the checks shared by the create and edit forms
+func validateSnippet(form *forms.Form) {
+	form.MaxLength("title", 100)
+}
+
+// validateExpiry checks the expires option of a form, along with the custom
+// duration or date it may come with. The extra options are permitted on top
+// of the ones shared by the create and edit forms.
+func validateExpiry(form *forms.Form, extra ...string) {
+	opts := append([]string{"365", "7", "1", expiresNever, expiresIn, expiresAt}, extra...)
+	form.PermittedValues("expires", opts...)
+
+	switch form.Get("expires") {
+	case expiresIn:
+		form.Required("expires_in")
+		form.Duration("expires_in", time.Minute, maxExpiry)
+	case expiresAt:
+		form.Required("expires_at")
+		form.FutureTime("expires_at", maxExpiry)
+	}
+}
+
+// expiryTime returns when a snippet saved at now expires according to the
+// validated expires option of a form, the zero time when it never expires
+func expiryTime(form *forms.Form, now time.Time) time.Time {
+	switch expires := form.Get("expires"); expires {
+	case expiresNever:
+		return time.Time{}
+	case expiresAfterFirstView:
+		return now.Add(afterFirstViewExpiry)
+	case expiresIn:
+		d, _ := forms.ParseDuration(form.Get("expires_in"))
+		return now.Add(d)
+	case expiresAt:
+		t, _ := time.Parse(forms.DateTimeLayout, form.Get("expires_at"))
+		return t
+	default:
+		days, _ := strconv.Atoi(expires)
+		return now.AddDate(0, 0, days)
+	}
 }
 
 func (app *application) signupUserForm(w http.ResponseWriter, r *http.Request) {
@@ -92,6 +653,8 @@ func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
 	form.MaxLength("email", 255)
 	form.Email("email")
 	form.MinLength("password", 10)
+	// bcrypt doesn't hash passwords over 72 bytes
+	form.MaxBytes("password", 72)
 
 	if !form.Valid() {
 		app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
@@ -103,6 +666,9 @@ func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.Errors.Add("email", "Address is already in use")
 			app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
+		} else if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			form.Errors.Add("password", "This field's value is too long. It must have a maximum length of 72 bytes")
+			app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
 		} else {
 			app.serverError(w, err)
 		}
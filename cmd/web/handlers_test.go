@@ -1,8 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"html"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
 )
 
 func TestPing(t *testing.T) {
@@ -25,3 +37,1311 @@ func TestPing(t *testing.T) {
 		t.Errorf("want body equal to %q", "OK")
 	}
 }
+
+func TestShowSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid ID", "/snippet/1", http.StatusOK, []byte("An old silent pond...")},
+		{"Author name", "/snippet/1", http.StatusOK, []byte("By Alice")},
+		{"Non-existent ID", "/snippet/2", http.StatusNotFound, nil},
+		{"Negative ID", "/snippet/-1", http.StatusNotFound, nil},
+		{"Decimal ID", "/snippet/1.23", http.StatusNotFound, nil},
+		{"String ID", "/snippet/foo", http.StatusNotFound, nil},
+		{"Empty ID", "/snippet/", http.StatusNotFound, nil},
+		{"Trailing slash", "/snippet/1/", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d, got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCreateSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/create")
+
+		if code != http.StatusSeeOther {
+			t.Errorf("want %d, got %d", http.StatusSeeOther, code)
+		}
+
+		if loc := headers.Get("Location"); loc != "/user/login" {
+			t.Errorf("want %q, got %q", "/user/login", loc)
+		}
+	})
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name         string
+		title        string
+		content      string
+		expires      string
+		tags         string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Valid submission", "Haiku", "An old silent pond...", "7", "Poetry, haiku,,poetry", http.StatusSeeOther, "/snippet/2", nil},
+		{"Empty title", "", "An old silent pond...", "7", "", http.StatusOK, "", []byte("This field cannot be blank.")},
+		{"Long title", string(bytes.Repeat([]byte("a"), 101)), "An old silent pond...", "7", "", http.StatusOK, "", []byte("maximum length of 100")},
+		{"Invalid expires", "Haiku", "An old silent pond...", "2", "", http.StatusOK, "", []byte("This field&#39;s value is invalid.")},
+		{"Too many tags", "Haiku", "An old silent pond...", "7", "a, b, c, d, e, f", http.StatusOK, "", []byte("at most 5 items")},
+		{"Invalid tag", "Haiku", "An old silent pond...", "7", "poetry, old pond", http.StatusOK, "", []byte("The tag &#34;old pond&#34; is invalid")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("expires", tt.expires)
+			form.Add("tags", tt.tags)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, "/snippet/create", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d, got %d", tt.wantCode, code)
+			}
+
+			if loc := headers.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want location %q, got %q", tt.wantLocation, loc)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	// bcrypt's limit is in bytes, 72 characters can be more
+	t.Run("Long password", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("title", "Haiku")
+		form.Add("content", "An old silent pond...")
+		form.Add("expires", "7")
+		form.Add("password", strings.Repeat("é", 72))
+		form.Add("csrf_token", csrfToken)
+
+		code, _, body := ts.postForm(t, "/snippet/create", form)
+		if code != http.StatusOK || !bytes.Contains(body, []byte("maximum length of 72 bytes")) {
+			t.Errorf("want the form with an error, got %d", code)
+		}
+	})
+
+	t.Run("Records the author", func(t *testing.T) {
+		s, err := app.snippets.Get(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !s.IsOwner(1) {
+			t.Errorf("want snippet owned by user 1, got %d", s.UserID)
+		}
+
+		if want := []string{"haiku", "poetry"}; !reflect.DeepEqual(s.Tags, want) {
+			t.Errorf("want tags %q, got %q", want, s.Tags)
+		}
+	})
+}
+
+func TestSignupUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/signup")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		userName     string
+		userEmail    string
+		userPassword string
+		csrfToken    string
+		wantCode     int
+		wantBody     []byte
+	}{
+		{"Valid submission", "Bob", "bob@example.com", "validPa$$word", csrfToken, http.StatusSeeOther, nil},
+		{"Empty name", "", "bob@example.com", "validPa$$word", csrfToken, http.StatusOK, []byte("This field cannot be blank.")},
+		{"Empty email", "Bob", "", "validPa$$word", csrfToken, http.StatusOK, []byte("This field cannot be blank.")},
+		{"Empty password", "Bob", "bob@example.com", "", csrfToken, http.StatusOK, []byte("This field cannot be blank.")},
+		{"Invalid email", "Bob", "bob@example.", "validPa$$word", csrfToken, http.StatusOK, []byte("This field is invalid")},
+		{"Short password", "Bob", "bob@example.com", "pa$$word", csrfToken, http.StatusOK, []byte("This field is too short (minimum is 10 characters)")},
+		{"Long password", "Bob", "bob@example.com", strings.Repeat("a", 80), csrfToken, http.StatusOK, []byte("maximum length of 72 bytes")},
+		{"Duplicate email", "Bob", "alice@example.com", "validPa$$word", csrfToken, http.StatusOK, []byte("Address is already in use")},
+		{"Invalid CSRF Token", "", "", "", "wrongToken", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.userEmail)
+			form.Add("password", tt.userPassword)
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d, got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestLoginUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		userEmail    string
+		userPassword string
+		wantCode     int
+		wantBody     []byte
+	}{
+		{"Valid credentials", "alice@example.com", "validPa$$word", http.StatusSeeOther, nil},
+		{"Wrong password", "alice@example.com", "wrongPa$$word", http.StatusOK, []byte("Email or password is incorrect.")},
+		{"Unknown email", "bob@example.com", "validPa$$word", http.StatusOK, []byte("Email or password is incorrect.")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.userEmail)
+			form.Add("password", tt.userPassword)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/login", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d, got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEditSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// A snippet owned by someone else
+	if _, err := app.snippets.Insert(&models.Snippet{UserID: 2, Title: "Not yours", Content: "Not yours"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/1/edit")
+
+		if code != http.StatusSeeOther || headers.Get("Location") != "/user/login" {
+			t.Errorf("want redirect to /user/login, got %d %q", code, headers.Get("Location"))
+		}
+	})
+
+	csrfToken := ts.login(t)
+
+	t.Run("Pre-populated form", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/1/edit")
+
+		if code != http.StatusOK {
+			t.Errorf("want %d, got %d", http.StatusOK, code)
+		}
+
+		if !bytes.Contains(body, []byte("<textarea name='content'>An old silent pond...</textarea>")) {
+			t.Errorf("want the form to contain the current content")
+		}
+	})
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		title        string
+		expires      string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Valid submission", "/snippet/1/edit", "Haiku", "", http.StatusSeeOther, "/snippet/1", nil},
+		{"New expiry", "/snippet/1/edit", "Haiku", "1", http.StatusSeeOther, "/snippet/1", nil},
+		{"Empty title", "/snippet/1/edit", "", "", http.StatusOK, "", []byte("This field cannot be blank.")},
+		{"Invalid expires", "/snippet/1/edit", "Haiku", "2", http.StatusOK, "", []byte("This field&#39;s value is invalid.")},
+		{"Not the owner", "/snippet/2/edit", "Haiku", "", http.StatusForbidden, "", nil},
+		{"Non-existent ID", "/snippet/3/edit", "Haiku", "", http.StatusNotFound, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", "An old silent pond, a frog jumps into the pond")
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d, got %d", tt.wantCode, code)
+			}
+
+			if loc := headers.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want location %q, got %q", tt.wantLocation, loc)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	s, err := app.snippets.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Title != "Haiku" {
+		t.Errorf("want title %q, got %q", "Haiku", s.Title)
+	}
+}
+
+func TestDeleteSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// A snippet owned by someone else
+	if _, err := app.snippets.Insert(&models.Snippet{UserID: 2, Title: "Not yours", Content: "Not yours"}); err != nil {
+		t.Fatal(err)
+	}
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		csrfToken    string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Invalid CSRF token", "/snippet/1/delete", "wrongToken", http.StatusBadRequest, ""},
+		{"Not the owner", "/snippet/2/delete", csrfToken, http.StatusForbidden, ""},
+		{"Valid submission", "/snippet/1/delete", csrfToken, http.StatusSeeOther, "/"},
+		{"Already deleted", "/snippet/1/delete", csrfToken, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d, got %d", tt.wantCode, code)
+			}
+
+			if loc := headers.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want location %q, got %q", tt.wantLocation, loc)
+			}
+		})
+	}
+
+	_, _, body := ts.get(t, "/")
+	if !bytes.Contains(body, []byte("Snippet successfully deleted!")) {
+		t.Errorf("want the flash message on the home page")
+	}
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	err := app.snippets.Update(&models.Snippet{ID: 1, Title: "An old silent pond", Content: "An old silent pond...\nA frog jumps into the pond"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// html/template escapes the + of the inserted lines as &#43;
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"History", "/snippet/1/history", http.StatusOK, []byte("/snippet/1/diff?to=2")},
+		{"Latest changes", "/snippet/1/diff", http.StatusOK, []byte(`<span class="insert">&#43;A frog jumps into the pond</span>`)},
+		{"First version", "/snippet/1/diff?to=1", http.StatusOK, []byte(`<span class="insert">&#43;An old silent pond...</span>`)},
+		{"Same version", "/snippet/1/diff?from=2&to=2", http.StatusOK, []byte("The content is the same in both versions.")},
+		{"Unknown version", "/snippet/1/diff?from=1&to=3", http.StatusNotFound, nil},
+		{"Unknown original version", "/snippet/1/diff?from=3&to=2", http.StatusNotFound, nil},
+		{"Invalid version", "/snippet/1/diff?to=foo", http.StatusBadRequest, nil},
+		{"Unknown snippet", "/snippet/2/history", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d, got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetArchive(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// With the seeded snippet that's 2 full pages and 1 snippet on the third
+	for i := 2; i <= 2*pageSize+1; i++ {
+		if _, err := app.snippets.Insert(&models.Snippet{UserID: 1, Title: fmt.Sprintf("Snippet %d", i), Content: "Content"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, _, body := ts.get(t, "/snippets")
+	if code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+
+	if !bytes.Contains(body, []byte("Snippet 41")) || bytes.Contains(body, []byte("Snippet 21<")) {
+		t.Errorf("want the first page to hold snippets 41 to 22")
+	}
+
+	next := regexp.MustCompile(`<a class='next' href='([^']+)'>`)
+	prev := regexp.MustCompile(`<a class='prev' href='([^']+)'>`)
+	if prev.Match(body) {
+		t.Errorf("want no link to newer snippets on the first page")
+	}
+
+	// Follow the links to the last page and back
+	for _, want := range []string{"Snippet 21<", "An old silent pond"} {
+		m := next.FindSubmatch(body)
+		if m == nil {
+			t.Fatalf("want a link to older snippets")
+		}
+
+		_, _, body = ts.get(t, html.UnescapeString(string(m[1])))
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want page to contain %q", want)
+		}
+	}
+
+	if next.Match(body) {
+		t.Errorf("want no link to older snippets on the last page")
+	}
+
+	m := prev.FindSubmatch(body)
+	if m == nil {
+		t.Fatalf("want a link to newer snippets on the last page")
+	}
+
+	_, _, body = ts.get(t, html.UnescapeString(string(m[1])))
+	if !bytes.Contains(body, []byte("Snippet 21<")) || !bytes.Contains(body, []byte("Snippet 2<")) {
+		t.Errorf("want the previous page to hold snippets 21 to 2")
+	}
+
+	if code, _, _ := ts.get(t, "/snippets?after=foo"); code != http.StatusBadRequest {
+		t.Errorf("want %d for an invalid cursor, got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if _, err := app.snippets.Insert(&models.Snippet{UserID: 1, Title: "A frog jumps in", Content: "The sound of <water>"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := app.snippets.Insert(&models.Snippet{UserID: 1, Title: "Locked haiku", Content: "A secret blossom", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"No query", "/search", http.StatusOK, []byte("Type some words")},
+		{"Title match", "/search?q=FROG", http.StatusOK, []byte("A <mark>frog</mark> jumps in")},
+		{"Content match", "/search?q=water", http.StatusOK, []byte("The sound of &lt;<mark>water</mark>&gt;")},
+		{"Every term", "/search?q=old+frog", http.StatusOK, []byte("No snippets match")},
+		{"Protected title", "/search?q=locked", http.StatusOK, []byte("<mark>Locked</mark> haiku")},
+		{"Protected content", "/search?q=blossom", http.StatusOK, []byte("No snippets match")},
+		{"Invalid cursor", "/search?q=pond&after=foo", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d, got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestTagSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if _, err := app.snippets.Insert(&models.Snippet{UserID: 1, Title: "A frog jumps in", Content: "The sound of water", Tags: []string{"frog", "haiku"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Tag page", "/tag/frog", http.StatusOK, []byte("<a href='/snippet/2'>A frog jumps in</a>")},
+		{"Uppercase tag", "/tag/Frog", http.StatusOK, []byte("<a href='/snippet/2'>A frog jumps in</a>")},
+		{"Unknown tag", "/tag/winter", http.StatusOK, []byte("No snippets have this tag.")},
+		{"Links on the show page", "/snippet/2", http.StatusOK, []byte("<a href='/tag/haiku'>#haiku</a>")},
+		{"Links on the home page", "/", http.StatusOK, []byte("<a class='tag' href='/tag/frog'>#frog</a>")},
+		{"Invalid cursor", "/tag/frog?after=foo", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d, got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetLanguage(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	form := url.Values{}
+	form.Add("title", "Hello")
+	form.Add("content", "package main\n\nfunc main() {}")
+	form.Add("expires", "7")
+	form.Add("language", "go")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusSeeOther || headers.Get("Location") != "/snippet/2" {
+		t.Fatalf("want a redirect to the new snippet, got %d %q", code, headers.Get("Location"))
+	}
+
+	_, _, body := ts.get(t, "/snippet/2")
+	for _, want := range []string{"Go #2", `>package</span>`} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	// The seeded snippet has no language
+	_, _, body = ts.get(t, "/snippet/1")
+	if !bytes.Contains(body, []byte("Plain text #1")) {
+		t.Errorf("want the snippet shown as plain text")
+	}
+
+	// Without a language it's guessed from the title
+	form.Set("title", "main.go")
+	form.Set("language", "")
+	if code, headers, _ = ts.postForm(t, "/snippet/create", form); headers.Get("Location") != "/snippet/3" {
+		t.Fatalf("want a redirect to the new snippet, got %d %q", code, headers.Get("Location"))
+	}
+
+	_, _, body = ts.get(t, "/snippet/3")
+	for _, want := range []string{"Go (detected, 90% sure) #3", `>package</span>`} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	form.Set("language", "cobol")
+	code, _, body = ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("This field&#39;s value is invalid.")) {
+		t.Errorf("want the form with an error for an unsupported language, got %d", code)
+	}
+}
+
+func TestRawAndDownloadSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	snippets := []*models.Snippet{
+		{UserID: 1, Title: "Hello, World!", Content: "<script>alert(1)</script>", Language: "go"},
+		{UserID: 1, Title: "main.go", Content: "package main", DetectedLanguage: "go", LanguageConfidence: 0.9},
+		{UserID: 1, Title: "¿¡!?", Content: "?"},
+	}
+	for _, s := range snippets {
+		if _, err := app.snippets.Insert(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Raw", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/snippet/2/raw")
+
+		if code != http.StatusOK || string(body) != "<script>alert(1)</script>" {
+			t.Errorf("want the content as is, got %d %q", code, body)
+		}
+
+		if ct := headers.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+			t.Errorf("want plain text, got %q", ct)
+		}
+
+		if nosniff := headers.Get("X-Content-Type-Options"); nosniff != "nosniff" {
+			t.Errorf("want nosniff, got %q", nosniff)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		wantCode   int
+		wantHeader string
+	}{
+		{"Title and language", "/snippet/2/download", http.StatusOK, `attachment; filename=hello-world.go`},
+		{"File name title", "/snippet/3/download", http.StatusOK, `attachment; filename=main.go`},
+		{"Plain text", "/snippet/1/download", http.StatusOK, `attachment; filename=an-old-silent-pond.txt`},
+		{"Nothing left of the title", "/snippet/4/download", http.StatusOK, `attachment; filename=snippet-4.txt`},
+		{"Unknown snippet", "/snippet/5/download", http.StatusNotFound, ""},
+		{"Unknown raw snippet", "/snippet/5/raw", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, _ := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d, got %d", tt.wantCode, code)
+			}
+
+			if cd := headers.Get("Content-Disposition"); cd != tt.wantHeader {
+				t.Errorf("want %q, got %q", tt.wantHeader, cd)
+			}
+		})
+	}
+}
+
+func TestSnippetVisibility(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	snippets := []*models.Snippet{
+		{UserID: 1, Title: "Unlisted haiku", Content: "By link only", Visibility: models.VisibilityUnlisted},
+		{UserID: 1, Title: "Private haiku", Content: "Alice only", Visibility: models.VisibilityPrivate},
+		{UserID: 2, Title: "Someone else's secret", Content: "Not for Alice", Visibility: models.VisibilityPrivate},
+	}
+	for _, s := range snippets {
+		if _, err := app.snippets.Insert(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, _, body := ts.get(t, "/")
+	for _, title := range []string{"Unlisted haiku", "Private haiku", "Someone else"} {
+		if bytes.Contains(body, []byte(title)) {
+			t.Errorf("want %q left out of the latest snippets", title)
+		}
+	}
+
+	if _, _, body = ts.get(t, "/search?q=haiku"); !bytes.Contains(body, []byte("No snippets match")) {
+		t.Errorf("want unlisted and private snippets left out of the search results")
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Unlisted by id", "/snippet/2", http.StatusNotFound},
+		{"Unlisted by slug", "/s/" + snippets[0].Slug, http.StatusOK},
+		{"Unlisted raw by slug", "/s/" + snippets[0].Slug + "/raw", http.StatusOK},
+		{"Wrong slug", "/s/not-a-slug", http.StatusNotFound},
+		{"Private", "/snippet/3", http.StatusNotFound},
+		{"Private by slug", "/s/" + snippets[1].Slug, http.StatusNotFound},
+		{"Private raw", "/snippet/3/raw", http.StatusNotFound},
+		{"Private history", "/snippet/3/history", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _, _ := ts.get(t, tt.urlPath); code != tt.wantCode {
+				t.Errorf("want %d, got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	ts.login(t)
+
+	if code, _, body := ts.get(t, "/snippet/3"); code != http.StatusOK || !bytes.Contains(body, []byte("Alice only")) {
+		t.Errorf("want the author to see their private snippet, got %d", code)
+	}
+
+	if code, _, _ := ts.get(t, "/snippet/2"); code != http.StatusOK {
+		t.Errorf("want the author to reach their unlisted snippet by id, got %d", code)
+	}
+
+	if code, _, _ := ts.get(t, "/snippet/4"); code != http.StatusNotFound {
+		t.Errorf("want %d for the private snippet of another user, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestBurnAfterReading(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	form := url.Values{}
+	form.Add("title", "Wifi password")
+	form.Add("content", "correct horse battery staple")
+	form.Add("expires", "view")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/snippet/create", form)
+	location := headers.Get("Location")
+	if code != http.StatusSeeOther || !strings.HasPrefix(location, "/s/") {
+		t.Fatalf("want a redirect to the unlisted snippet, got %d %q", code, location)
+	}
+
+	// The author can look at it as often as they like
+	for i := 0; i < 2; i++ {
+		if code, _, _ := ts.get(t, location); code != http.StatusOK {
+			t.Fatalf("want the author to see the snippet, got %d", code)
+		}
+	}
+
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	if code, _, _ := ts.postForm(t, "/user/logout", form); code != http.StatusSeeOther {
+		t.Fatalf("logout: want %d, got %d", http.StatusSeeOther, code)
+	}
+
+	code, _, body := ts.get(t, location)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("correct horse battery staple")) {
+		t.Fatalf("want the first view to show the snippet, got %d", code)
+	}
+
+	for _, urlPath := range []string{location, location + "/raw"} {
+		if code, _, _ := ts.get(t, urlPath); code != http.StatusNotFound {
+			t.Errorf("%s: want %d after the first view, got %d", urlPath, http.StatusNotFound, code)
+		}
+	}
+}
+
+func TestProtectedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	for _, title := range []string{"Door code", "Safe code"} {
+		s := &models.Snippet{UserID: 2, Title: title, Content: "1234", Password: "open sesame"}
+		if _, err := app.snippets.Insert(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, _, body := ts.get(t, "/snippet/2")
+	if code != http.StatusOK || !bytes.Contains(body, []byte("enter its password")) || bytes.Contains(body, []byte("1234")) {
+		t.Errorf("want the unlock form instead of the content, got %d", code)
+	}
+
+	// curl and the like would save the unlock page as the snippet
+	for _, urlPath := range []string{"/snippet/2/raw", "/snippet/2/download"} {
+		code, headers, body := ts.get(t, urlPath)
+		if code != http.StatusForbidden || bytes.Contains(body, []byte("1234")) {
+			t.Errorf("%s: want %d without the content, got %d", urlPath, http.StatusForbidden, code)
+		}
+
+		if ct := headers.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("%s: want plain text, got %q", urlPath, ct)
+		}
+	}
+
+	csrfToken := extractCSRFToken(t, body)
+
+	unlock := func(urlPath, password string) (int, []byte) {
+		form := url.Values{}
+		form.Add("password", password)
+		form.Add("csrf_token", csrfToken)
+		code, _, body := ts.postForm(t, urlPath, form)
+		return code, body
+	}
+
+	if code, body := unlock("/snippet/2/unlock", "wrong"); code != http.StatusOK || !bytes.Contains(body, []byte("Password is incorrect.")) {
+		t.Errorf("want the unlock form with an error, got %d", code)
+	}
+
+	if code, _ := unlock("/snippet/2/unlock", "open sesame"); code != http.StatusSeeOther {
+		t.Fatalf("want a redirect after unlocking, got %d", code)
+	}
+
+	if _, _, body := ts.get(t, "/snippet/2/raw"); string(body) != "1234" {
+		t.Errorf("want the content once unlocked, got %q", body)
+	}
+
+	// Unlocking one snippet doesn't unlock the others
+	if _, _, body := ts.get(t, "/snippet/3/raw"); bytes.Contains(body, []byte("1234")) {
+		t.Errorf("want the other snippet still locked")
+	}
+
+	for i := 0; i < maxUnlockAttempts; i++ {
+		unlock("/snippet/3/unlock", "wrong")
+	}
+
+	if code, _ := unlock("/snippet/3/unlock", "open sesame"); code != http.StatusTooManyRequests {
+		t.Errorf("want %d after too many attempts, got %d", http.StatusTooManyRequests, code)
+	}
+
+	// Another session has its own attempts
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+
+	_, _, body = ts.get(t, "/snippet/3")
+	csrfToken = extractCSRFToken(t, body)
+
+	if code, _ := unlock("/snippet/3/unlock", "open sesame"); code != http.StatusSeeOther {
+		t.Errorf("want a redirect with a new session, got %d", code)
+	}
+}
+
+func TestSnippetExpiry(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+	at := time.Now().UTC().Add(48 * time.Hour).Truncate(time.Minute)
+
+	tests := []struct {
+		name        string
+		expires     string
+		expiresIn   string
+		expiresAt   string
+		wantExpires time.Time // zero for never
+		wantError   string
+	}{
+		{"Never", "never", "", "", time.Time{}, ""},
+		{"Custom duration", "in", "3h", "", time.Now().Add(3 * time.Hour), ""},
+		{"Custom date", "at", "", at.Format("2006-01-02T15:04"), at, ""},
+		{"Missing duration", "in", "", "", time.Time{}, "This field cannot be blank."},
+		{"Invalid duration", "in", "soon", "", time.Time{}, "This field must be a duration like 3h or 30d"},
+		{"Too long", "in", "4000d", "", time.Time{}, "This field must be between 1m and 3650d"},
+		{"Past date", "at", "", "2001-02-03T04:05", time.Time{}, "This field must be in the future"},
+		{"Too far date", "at", "", "9999-12-31T23:59", time.Time{}, "This field must be at most 3650d from now"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Haiku")
+			form.Add("content", "An old silent pond...")
+			form.Add("expires", tt.expires)
+			form.Add("expires_in", tt.expiresIn)
+			form.Add("expires_at", tt.expiresAt)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, "/snippet/create", form)
+			if tt.wantError != "" {
+				if code != http.StatusOK || !bytes.Contains(body, []byte(tt.wantError)) {
+					t.Errorf("want the form with %q, got %d", tt.wantError, code)
+				}
+				return
+			}
+
+			if code != http.StatusSeeOther {
+				t.Fatalf("want %d, got %d", http.StatusSeeOther, code)
+			}
+
+			var id int
+			fmt.Sscanf(headers.Get("Location"), "/snippet/%d", &id)
+			s, err := app.snippets.Get(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := s.Expires.Sub(tt.wantExpires); d < -time.Minute || d > time.Minute {
+				t.Errorf("want expires %v, got %v", tt.wantExpires, s.Expires)
+			}
+		})
+	}
+
+	if _, _, body := ts.get(t, "/snippet/2"); !bytes.Contains(body, []byte("Expires: Never")) {
+		t.Errorf("want the snippet that never expires shown as such")
+	}
+}
+
+func TestExtendSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if _, err := app.snippets.Insert(&models.Snippet{UserID: 2, Title: "Not yours", Content: "Not yours"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := app.snippets.Insert(&models.Snippet{UserID: 2, ForkOf: 1, Title: "A fork", Content: "A fork"}); err != nil {
+		t.Fatal(err)
+	}
+
+	csrfToken := ts.login(t)
+
+	// The seeded snippet expires in a week
+	if _, _, body := ts.get(t, "/snippet/1"); !bytes.Contains(body, []byte("(in 6 days)")) {
+		t.Errorf("want the countdown on the show page")
+	}
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		expires   string
+		wantCode  int
+		wantError string
+	}{
+		{"Earlier", "/snippet/1/extend", "1", http.StatusOK, "The snippet already expires later than that."},
+		{"Invalid", "/snippet/1/extend", "2", http.StatusOK, "This field&#39;s value is invalid."},
+		{"Not the owner", "/snippet/2/extend", "365", http.StatusForbidden, ""},
+		{"Valid", "/snippet/1/extend", "365", http.StatusSeeOther, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d, got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, []byte(tt.wantError)) {
+				t.Errorf("want body to contain %q", tt.wantError)
+			}
+
+			// The page shown again with the error is the whole show page
+			if code == http.StatusOK && !bytes.Contains(body, []byte("A fork")) {
+				t.Errorf("want the forks listed with the error")
+			}
+		})
+	}
+
+	s, err := app.snippets.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Now().AddDate(1, 0, 0); s.Expires.Before(want.Add(-time.Minute)) {
+		t.Errorf("want the expiry a year from now, got %v", s.Expires)
+	}
+
+	revisions, err := app.snippets.Revisions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revisions) != 1 {
+		t.Errorf("want no new revision for an extension, got %d revisions", len(revisions))
+	}
+}
+
+func TestSnippetViews(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if _, err := app.snippets.Insert(&models.Snippet{UserID: 2, Title: "Not yours", Content: "Not yours"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.get(t, "/snippet/1")
+	// The views that weren't flushed yet are shown too
+	if _, _, body := ts.get(t, "/snippet/1"); !bytes.Contains(body, []byte("2 views")) {
+		t.Errorf("want the pending views on the show page")
+	}
+
+	app.flushViewsOnce()
+
+	ts.login(t)
+
+	// The author's views don't count
+	if _, _, body := ts.get(t, "/snippet/1"); !bytes.Contains(body, []byte("2 views")) {
+		t.Errorf("want the author's view not to be counted")
+	}
+
+	s, err := app.snippets.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Views != 2 || s.LastViewed.IsZero() {
+		t.Errorf("want 2 flushed views, got %d last viewed at %v", s.Views, s.LastViewed)
+	}
+
+	code, _, body := ts.get(t, "/snippet/1/stats")
+	if code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+
+	if !bytes.Contains(body, []byte("Viewed 2 times")) {
+		t.Errorf("want the total views on the stats page")
+	}
+
+	if n := bytes.Count(body, []byte("<progress")); n != statsDays {
+		t.Errorf("want %d days, got %d", statsDays, n)
+	}
+
+	if code, _, _ = ts.get(t, "/snippet/2/stats"); code != http.StatusForbidden {
+		t.Errorf("want %d, got %d", http.StatusForbidden, code)
+	}
+}
+
+func TestForkSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	code, _, body := ts.get(t, "/snippet/1/fork")
+	if code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+
+	for _, want := range []string{
+		"Forking <a href='/snippet/1'>",
+		`<input type="hidden" name="fork_of" value="1">`,
+		"<textarea name='content'>An old silent pond...</textarea>",
+	} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want the form to contain %q", want)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		forkOf     string
+		wantCode   int
+		wantForkOf int
+	}{
+		{"Fork", "1", http.StatusSeeOther, 1},
+		// The fork is kept on its own when the original is gone
+		{"Missing original", "99", http.StatusSeeOther, 0},
+		{"Invalid original", "one", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "A forked pond")
+			form.Add("content", "A frog jumps in")
+			form.Add("expires", "7")
+			form.Add("fork_of", tt.forkOf)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Fatalf("want %d, got %d", tt.wantCode, code)
+			}
+
+			if code != http.StatusSeeOther {
+				return
+			}
+
+			var id int
+			fmt.Sscanf(headers.Get("Location"), "/snippet/%d", &id)
+
+			s, err := app.snippets.Get(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if s.ForkOf != tt.wantForkOf {
+				t.Errorf("want fork of %d, got %d", tt.wantForkOf, s.ForkOf)
+			}
+		})
+	}
+
+	if _, _, body = ts.get(t, "/snippet/2"); !bytes.Contains(body, []byte("Forked from <a href='/snippet/1'>#1</a>")) {
+		t.Errorf("want the fork to link to its original")
+	}
+
+	_, _, body = ts.get(t, "/snippet/1")
+	if !bytes.Contains(body, []byte("<a href='/snippet/2'>A forked pond</a>")) {
+		t.Errorf("want the original to list its fork")
+	}
+
+	if bytes.Contains(body, []byte("/snippet/3'")) {
+		t.Errorf("want only the forks of the snippet listed")
+	}
+}
+
+func TestMarkdownSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	form := url.Values{}
+	form.Add("title", "Runbook")
+	form.Add("content", "# Restart\n\n<script>alert(1)</script>\n\n- [status](https://example.com)")
+	form.Add("format", "markdown")
+	form.Add("language", "go")
+	form.Add("expires", "7")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d, got %d", http.StatusSeeOther, code)
+	}
+	location := headers.Get("Location")
+
+	_, _, body := ts.get(t, location)
+	for _, want := range []string{"<h1>Restart</h1>", `<a href="https://example.com" rel="nofollow noopener" target="_blank">status</a>`, "Markdown #2"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	if bytes.Contains(body, []byte("alert(1)")) {
+		t.Errorf("want the raw HTML left out")
+	}
+
+	// The cached HTML is replaced by the new revision
+	form = url.Values{}
+	form.Add("title", "Runbook")
+	form.Add("content", "## Restart twice")
+	form.Add("csrf_token", csrfToken)
+
+	if code, _, _ = ts.postForm(t, location+"/edit", form); code != http.StatusSeeOther {
+		t.Fatalf("want %d, got %d", http.StatusSeeOther, code)
+	}
+
+	if _, _, body = ts.get(t, location); !bytes.Contains(body, []byte("<h2>Restart twice</h2>")) {
+		t.Errorf("want the edited content rendered")
+	}
+
+	if _, headers, _ = ts.get(t, location+"/download"); !strings.Contains(headers.Get("Content-Disposition"), "runbook.md") {
+		t.Errorf("want a .md file, got %q", headers.Get("Content-Disposition"))
+	}
+
+	s, err := app.snippets.Get(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Language != "" || s.DetectedLanguage != "" {
+		t.Errorf("want no language for Markdown, got %q and %q", s.Language, s.DetectedLanguage)
+	}
+}
+
+func TestForkHiddenSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	hidden := []*models.Snippet{
+		{UserID: 2, Title: "Unlisted pond", Content: "Unlisted", Visibility: models.VisibilityUnlisted},
+		{UserID: 2, Title: "Protected pond", Content: "Protected", Password: "secret"},
+	}
+	for _, s := range hidden {
+		if _, err := app.snippets.Insert(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	csrfToken := ts.login(t)
+
+	for i, s := range hidden {
+		t.Run(s.Title, func(t *testing.T) {
+			id := strconv.Itoa(i + 2)
+
+			// An invalid form shows the original, it mustn't reveal it
+			form := url.Values{}
+			form.Add("title", "")
+			form.Add("content", "A frog jumps in")
+			form.Add("expires", "7")
+			form.Add("fork_of", id)
+			form.Add("csrf_token", csrfToken)
+
+			_, _, body := ts.postForm(t, "/snippet/create", form)
+			if bytes.Contains(body, []byte(s.Title)) || bytes.Contains(body, []byte(s.Slug)) {
+				t.Errorf("want the original hidden")
+			}
+
+			form.Set("title", "A forked pond")
+			code, headers, _ := ts.postForm(t, "/snippet/create", form)
+			if code != http.StatusSeeOther {
+				t.Fatalf("want %d, got %d", http.StatusSeeOther, code)
+			}
+
+			var forkID int
+			fmt.Sscanf(headers.Get("Location"), "/snippet/%d", &forkID)
+
+			fork, err := app.snippets.Get(forkID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if fork.ForkOf != 0 {
+				t.Errorf("want no original, got %d", fork.ForkOf)
+			}
+		})
+	}
+}
+
+func TestForkUnlistedSnippetBySlug(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	parent := &models.Snippet{UserID: 2, Title: "Unlisted pond", Content: "Unlisted", Visibility: models.VisibilityUnlisted}
+	id, err := app.snippets.Insert(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent.ID = id
+
+	csrfToken := ts.login(t)
+
+	code, _, body := ts.get(t, "/s/"+parent.Slug+"/fork")
+	if code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+
+	if !bytes.Contains(body, []byte(`name="fork_slug" value="`+parent.Slug+`"`)) {
+		t.Errorf("want the slug in the form")
+	}
+
+	form := url.Values{}
+	form.Add("title", "A forked pond")
+	form.Add("content", "A frog jumps in")
+	form.Add("expires", "7")
+	form.Add("fork_of", strconv.Itoa(parent.ID))
+	form.Add("fork_slug", parent.Slug)
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d, got %d", http.StatusSeeOther, code)
+	}
+
+	var forkID int
+	fmt.Sscanf(headers.Get("Location"), "/snippet/%d", &forkID)
+
+	fork, err := app.snippets.Get(forkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fork.ForkOf != parent.ID {
+		t.Errorf("want original %d, got %d", parent.ID, fork.ForkOf)
+	}
+
+	forks, err := app.snippets.Forks(parent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(forks) != 1 || forks[0].ID != forkID {
+		t.Errorf("want fork %d listed, got %v", forkID, forks)
+	}
+}
+
+func TestForkedFromHiddenSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	parent := &models.Snippet{UserID: 1, Title: "Unlisted pond", Content: "Unlisted", Visibility: models.VisibilityUnlisted}
+	if _, err := app.snippets.Insert(parent); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := app.snippets.Insert(&models.Snippet{UserID: 1, ForkOf: 2, Title: "A forked pond", Content: "Forked"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Anyone else gets no link, it would 404 or give the slug away
+	_, _, body := ts.get(t, "/snippet/3")
+	if !bytes.Contains(body, []byte("Forked from #2</em>")) {
+		t.Errorf("want the original shown without a link")
+	}
+
+	if bytes.Contains(body, []byte(parent.Slug)) {
+		t.Errorf("want the slug of the original hidden")
+	}
+
+	ts.login(t)
+
+	if _, _, body = ts.get(t, "/snippet/3"); !bytes.Contains(body, []byte("Forked from <a href='/s/"+parent.Slug+"'>#2</a>")) {
+		t.Errorf("want the author linked to their original")
+	}
+}
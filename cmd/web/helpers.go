@@ -2,14 +2,49 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
+	"regexp"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/eiliz/snippetbox/pkg/forms"
+	"github.com/eiliz/snippetbox/pkg/highlight"
+	"github.com/eiliz/snippetbox/pkg/models"
 	"github.com/justinas/nosurf"
 )
 
+// Runs of characters that aren't allowed in download file names
+var filenameRX = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// snippetFilename derives a file name for a snippet from its title and the
+// extension of its language, e.g. "Hello world" in Go is hello-world.go. A
+// title that already is a file name like main.go is kept as is.
+func snippetFilename(s *models.Snippet) string {
+	ext := highlight.Extension(s.HighlightLanguage())
+	if s.Format == models.FormatMarkdown {
+		ext = ".md"
+	}
+
+	name := strings.Trim(filenameRX.ReplaceAllString(strings.ToLower(s.Title), "-"), "-.")
+	if len(name) > 50 {
+		name = strings.TrimRight(name[:50], "-.")
+	}
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", s.ID)
+	}
+
+	if strings.HasSuffix(name, ext) {
+		return name
+	}
+
+	return name + ext
+}
+
 // The serverError helper writes an error message and stack trace to the
 // errorLog, then sends a generic 500 Internal Server Error response to the user
 func (app *application) serverError(w http.ResponseWriter, err error) {
@@ -32,6 +67,299 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
+// getSnippet returns the snippet whose slug or id is in the URL. When there's
+// no such snippet it sends the 404 (or 500) response itself and returns nil,
+// so the handler only has to return.
+func (app *application) getSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	var s *models.Snippet
+	var err error
+
+	if slug := r.URL.Query().Get(":slug"); slug != "" {
+		s, err = app.snippets.GetBySlug(slug)
+	} else {
+		id, convErr := strconv.Atoi(r.URL.Query().Get(":id"))
+		if convErr != nil || id < 1 {
+			app.notFound(w)
+			return nil
+		}
+
+		s, err = app.snippets.Get(id)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return nil
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	// Private snippets don't exist for anyone but their author
+	if !s.IsVisibleTo(userID) {
+		app.notFound(w)
+		return nil
+	}
+
+	// Ids are sequential, so only public snippets can be reached with them.
+	// Anyone else needs the slug, unless it's the author.
+	if r.URL.Query().Get(":slug") == "" && s.Visibility != models.VisibilityPublic && !s.IsOwner(userID) {
+		app.notFound(w)
+		return nil
+	}
+
+	return s
+}
+
+// isLocked reports whether a snippet is protected by a password that the
+// current session hasn't entered yet. The author never needs the password.
+func (app *application) isLocked(r *http.Request, s *models.Snippet) bool {
+	return s.Protected && !s.IsOwner(app.authenticatedUserID(r)) && !app.session.GetBool(r, unlockedKey(s.ID))
+}
+
+// The session key remembering that a snippet was unlocked
+func unlockedKey(id int) string {
+	return fmt.Sprintf("unlocked.%d", id)
+}
+
+// readSnippet is getSnippet for the pages showing the content of a snippet.
+// Anyone but the author reading a burn after reading snippet consumes it, so
+// it's gone for every later request.
+func (app *application) readSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	s := app.getSnippet(w, r)
+	if s == nil {
+		return nil
+	}
+
+	// Protected snippets ask for their password instead, before a burn after
+	// reading one is consumed.
+	if app.isLocked(r, s) {
+		app.render(w, r, "unlock.page.tmpl", &templateData{Form: forms.New(nil), Snippet: s})
+		return nil
+	}
+
+	return app.consumeSnippet(w, r, s)
+}
+
+// readRawSnippet is readSnippet for the plain text routes, which are mostly
+// used by curl and the like: a protected snippet gets a plain text error
+// instead of the unlock page, which would be saved as the snippet.
+func (app *application) readRawSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	s := app.getSnippet(w, r)
+	if s == nil {
+		return nil
+	}
+
+	if app.isLocked(r, s) {
+		http.Error(w, "This snippet is protected, unlock it with its password at "+s.Path(), http.StatusForbidden)
+		return nil
+	}
+
+	return app.consumeSnippet(w, r, s)
+}
+
+// consumeSnippet consumes a burn after reading snippet read by anyone but its
+// author and returns it. Like getSnippet it sends the error response itself
+// and returns nil when it fails.
+func (app *application) consumeSnippet(w http.ResponseWriter, r *http.Request, s *models.Snippet) *models.Snippet {
+	if !s.BurnAfterReading || s.IsOwner(app.authenticatedUserID(r)) {
+		return s
+	}
+
+	s, err := app.snippets.Consume(s.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return nil
+	}
+
+	return s
+}
+
+// showPageData assembles the data of show.page.tmpl for a snippet: its views,
+// the snippet it was forked from and the forks the user may see, and the
+// rendered Markdown. Like getSnippet it
+// sends the error response itself and returns nil when it fails.
+func (app *application) showPageData(w http.ResponseWriter, r *http.Request, s *models.Snippet, form *forms.Form) *templateData {
+	// Views are written to the store in batches, the ones waiting to be
+	// written count too.
+	app.addPendingViews(s)
+
+	userID := app.authenticatedUserID(r)
+
+	// The original is only linked when the viewer could open it, the link to
+	// an unlisted one would give its slug away. It may be gone by now too.
+	var parent *models.Snippet
+	if s.ForkOf != 0 {
+		p, err := app.snippets.Get(s.ForkOf)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return nil
+		}
+
+		if err == nil && (p.Visibility == models.VisibilityPublic || p.IsOwner(userID)) {
+			parent = p
+		}
+	}
+
+	forks, err := app.snippets.Forks(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return nil
+	}
+
+	// Only the public forks are listed, unless they're the viewer's own
+	listed := []*models.Snippet{}
+	for _, f := range forks {
+		if f.Visibility == models.VisibilityPublic || f.IsOwner(userID) {
+			listed = append(listed, f)
+		}
+	}
+
+	var rendered template.HTML
+	if s.Format == models.FormatMarkdown {
+		rendered, err = app.markdown.html(s)
+		if err != nil {
+			app.serverError(w, err)
+			return nil
+		}
+	}
+
+	return &templateData{Form: form, Snippet: s, Snippets: listed, Parent: parent, Markdown: rendered}
+}
+
+// forkedSnippet returns the snippet a new one is forked from, according to the
+// fork_slug or fork_of field of the create form. The snippet is nil when the
+// form isn't a fork, or when the original is gone or couldn't be read by the
+// current user: the fork is then saved on its own like the forks of deleted
+// snippets. Like getSnippet it sends the error response itself and returns
+// false when it fails.
+func (app *application) forkedSnippet(w http.ResponseWriter, r *http.Request, form *forms.Form) (*models.Snippet, bool) {
+	value, slug := form.Get("fork_of"), form.Get("fork_slug")
+
+	var s *models.Snippet
+	var err error
+	switch {
+	case slug != "":
+		s, err = app.snippets.GetBySlug(slug)
+	case value != "":
+		id, convErr := strconv.Atoi(value)
+		if convErr != nil || id < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return nil, false
+		}
+
+		s, err = app.snippets.Get(id)
+	default:
+		return nil, true
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, true
+		}
+
+		app.serverError(w, err)
+		return nil, false
+	}
+
+	// The original is shown on the form, so the rules of getSnippet apply:
+	// private snippets are the author's, the others need their slug unless
+	// they're public, and protected ones must be unlocked.
+	userID := app.authenticatedUserID(r)
+	if !s.IsVisibleTo(userID) {
+		return nil, true
+	}
+
+	if slug == "" && s.Visibility != models.VisibilityPublic && !s.IsOwner(userID) {
+		return nil, true
+	}
+
+	if app.isLocked(r, s) {
+		return nil, true
+	}
+
+	return s, true
+}
+
+// revision returns a version of a snippet. Like getSnippet it sends the error
+// response itself, a 404 for unknown versions, and returns false when it
+// fails.
+func (app *application) revision(w http.ResponseWriter, snippetID, version int) (*models.Revision, bool) {
+	rev, err := app.snippets.Revision(snippetID, version)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w)
+		return nil, false
+	} else if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+
+	return rev, true
+}
+
+// queryInt reads an integer from the query string, returning def when the
+// parameter is missing
+func queryInt(r *http.Request, key string, def int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+// pageCursors reads the after and before cursors of a paginated listing from
+// the query string
+func pageCursors(r *http.Request) (after, before *models.Cursor, err error) {
+	if v := r.URL.Query().Get("after"); v != "" {
+		if after, err = models.ParseCursor(v); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if v := r.URL.Query().Get("before"); v != "" {
+		if before, err = models.ParseCursor(v); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return after, before, nil
+}
+
+// setPageLinks fills in the links to the previous and next pages of a listing,
+// keeping the other query string parameters of the request, like a search
+// query.
+func setPageLinks(td *templateData, r *http.Request, page *models.SnippetPage) {
+	link := func(key string, c *models.Cursor) string {
+		q := r.URL.Query()
+		q.Del("after")
+		q.Del("before")
+		// pat adds the route parameters to the query as :name, they're already
+		// part of the path.
+		for k := range q {
+			if strings.HasPrefix(k, ":") {
+				q.Del(k)
+			}
+		}
+		q.Set(key, c.String())
+		return r.URL.Path + "?" + q.Encode()
+	}
+
+	if page.Prev != nil {
+		td.PrevPage = link("before", page.Prev)
+	}
+
+	if page.Next != nil {
+		td.NextPage = link("after", page.Next)
+	}
+}
+
 func (app *application) render(w http.ResponseWriter, r *http.Request, name string, td *templateData) {
 	ts, ok := app.templateCache[name]
 
@@ -58,11 +386,26 @@ func (app *application) addDefaultData(td *templateData, r *http.Request) *templ
 	td.CurrentYear = time.Now().Year()
 	td.Flash = app.session.PopString(r, "flash")
 	td.IsAuthenticated = app.isAuthenticated(r)
+	td.AuthenticatedUserID = app.authenticatedUserID(r)
 	td.CSRFToken = nosurf.Token(r)
 
 	return td
 }
 
+// Returns the ID of the logged in user or 0 for anonymous requests
+func (app *application) authenticatedUserID(r *http.Request) int {
+	if !app.isAuthenticated(r) {
+		return 0
+	}
+
+	return app.session.GetInt(r, "authenticatedUserID")
+}
+
+// Only the owner of a snippet may modify or delete it
+func (app *application) canModify(r *http.Request, s *models.Snippet) bool {
+	return s.IsOwner(app.authenticatedUserID(r))
+}
+
 func (app *application) isAuthenticated(r *http.Request) bool {
 	isAuthenticated, ok := r.Context().Value(contextKeyIsAuthenticated).(bool)
 	if !ok {
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"html/template"
+	"sync"
+
+	"github.com/eiliz/snippetbox/pkg/markdown"
+	"github.com/eiliz/snippetbox/pkg/models"
+)
+
+// How many rendered Markdown snippets are kept in memory
+const markdownCacheSize = 1000
+
+type renderedMarkdown struct {
+	version int
+	html    template.HTML
+}
+
+// markdownCache keeps the HTML of the Markdown snippets by id, along with the
+// revision it was rendered from. A snippet is only rendered again once it's
+// edited.
+type markdownCache struct {
+	mu       sync.Mutex
+	rendered map[int]renderedMarkdown
+}
+
+func newMarkdownCache() *markdownCache {
+	return &markdownCache{rendered: map[int]renderedMarkdown{}}
+}
+
+// html returns the content of a Markdown snippet as sanitized HTML
+func (c *markdownCache) html(s *models.Snippet) (template.HTML, error) {
+	c.mu.Lock()
+	cached, ok := c.rendered[s.ID]
+	c.mu.Unlock()
+
+	if ok && s.Version != 0 && cached.version == s.Version {
+		return cached.html, nil
+	}
+
+	html, err := markdown.HTML(s.Content)
+	if err != nil {
+		return "", err
+	}
+
+	// Snippets without a known version aren't cached, they couldn't be told
+	// apart from their later revisions.
+	if s.Version == 0 {
+		return html, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Make room by dropping any entry, the map is only there to spare the
+	// renders of the snippets viewed over and over.
+	if _, ok := c.rendered[s.ID]; !ok && len(c.rendered) >= markdownCacheSize {
+		for id := range c.rendered {
+			delete(c.rendered, id)
+			break
+		}
+	}
+
+	// A request that read the snippet before it was edited mustn't replace the
+	// newer revision.
+	if current, ok := c.rendered[s.ID]; !ok || current.version < s.Version {
+		c.rendered[s.ID] = renderedMarkdown{version: s.Version, html: html}
+	}
+
+	return html, nil
+}
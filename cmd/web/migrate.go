@@ -0,0 +1,88 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/eiliz/snippetbox/pkg/migrations"
+)
+
+// The version of the schema of the databases set up by hand before there were
+// migrations: the snippets and users tables
+const handMadeSchemaVersion = 2
+
+// migrate runs the "migrate up|down|status|baseline" subcommand against the
+// database. Flags must come before the subcommand, i.e.
+// go run ./cmd/web -driver=sqlite3 -dsn=./snippetbox.db migrate up
+func migrate(db *sql.DB, driver string, args []string, infoLog *log.Logger) error {
+	m, err := migrations.New(db, driver)
+	if err != nil {
+		return err
+	}
+
+	action := ""
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		done, err := m.Up()
+		for _, mig := range done {
+			infoLog.Printf("Applied %04d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(done) == 0 {
+			infoLog.Print("Schema is up to date")
+		}
+	case "down":
+		mig, err := m.Down()
+		if err != nil {
+			return err
+		}
+
+		if mig == nil {
+			infoLog.Print("No migration to revert")
+		} else {
+			infoLog.Printf("Reverted %04d_%s", mig.Version, mig.Name)
+		}
+	case "baseline":
+		// "migrate baseline [version]" marks the existing tables as migrated
+		version := handMadeSchemaVersion
+		if len(args) > 1 {
+			if version, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid baseline version %q", args[1])
+			}
+		}
+
+		done, err := m.Baseline(version)
+		for _, mig := range done {
+			infoLog.Printf("Recorded %04d_%s as applied", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + humanDate(s.AppliedAt)
+			}
+			infoLog.Printf("%04d_%s\t%s", s.Version, s.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate action %q, want up, down, status or baseline", action)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// How many expired snippets are deleted per statement. Deleting in batches
+// keeps the transactions, and the locks they hold, short.
+const purgeBatchSize = 100
+
+// purgeExpiredSnippets deletes the expired snippets every interval until the
+// context is cancelled. The expiry filters already hide them, this only stops
+// them from piling up in the database.
+func (app *application) purgeExpiredSnippets(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.purgeOnce(ctx)
+		}
+	}
+}
+
+// purgeOnce deletes batches of expired snippets until there are none left
+func (app *application) purgeOnce(ctx context.Context) {
+	total := 0
+
+	for ctx.Err() == nil {
+		n, err := app.snippets.DeleteExpired(time.Now(), purgeBatchSize)
+		if err != nil {
+			app.errorLog.Printf("purging expired snippets: %s", err)
+			break
+		}
+
+		total += n
+		if n < purgeBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		app.infoLog.Printf("Purged %d expired snippets", total)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+)
+
+func TestPurgeExpiredSnippets(t *testing.T) {
+	app := newTestApplication(t)
+
+	infoLog := new(bytes.Buffer)
+	app.infoLog = log.New(infoLog, "", 0)
+
+	// More than a batch of snippets which expire straight away
+	for i := 0; i < purgeBatchSize+5; i++ {
+		if _, err := app.snippets.Insert(&models.Snippet{UserID: 1, Title: "Expired", Content: "Expired", Expires: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		app.purgeExpiredSnippets(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the purge didn't stop after the context was cancelled")
+	}
+
+	want := "Purged 105 expired snippets"
+	if !bytes.Contains(infoLog.Bytes(), []byte(want)) {
+		t.Errorf("want log to contain %q, got %q", want, infoLog.String())
+	}
+
+	// The seeded snippet hasn't expired and must still be there
+	if _, err := app.snippets.Get(1); err != nil {
+		t.Error(err)
+	}
+}
@@ -17,11 +17,35 @@ func (app *application) routes() http.Handler {
 	mux := pat.New()
 
 	mux.Get("/", dynamicMiddleware.Then(http.HandlerFunc(app.home)))
+	mux.Get("/snippets", dynamicMiddleware.Then(http.HandlerFunc(app.snippetArchive)))
+	mux.Get("/search", dynamicMiddleware.Then(http.HandlerFunc(app.search)))
+	mux.Get("/tag/:name", dynamicMiddleware.Then(http.HandlerFunc(app.tagSnippets)))
 	// Register the exactly matched paths (snippet/create) before the snippet/:id
 	// because that one would match first otherwise.
 	mux.Get("/snippet/create", dynamicMiddleware.Append(app.requireAuthentication).Then(http.HandlerFunc(app.createSnippetForm)))
 	mux.Post("/snippet/create", dynamicMiddleware.Append(app.requireAuthentication).Then(http.HandlerFunc(app.createSnippet)))
+	mux.Get("/snippet/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).Then(http.HandlerFunc(app.editSnippetForm)))
+	mux.Post("/snippet/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).Then(http.HandlerFunc(app.editSnippet)))
+	mux.Post("/snippet/:id/extend", dynamicMiddleware.Append(app.requireAuthentication).Then(http.HandlerFunc(app.extendSnippet)))
+	mux.Get("/snippet/:id/fork", dynamicMiddleware.Append(app.requireAuthentication).Then(http.HandlerFunc(app.forkSnippetForm)))
+	mux.Get("/snippet/:id/stats", dynamicMiddleware.Append(app.requireAuthentication).Then(http.HandlerFunc(app.snippetStats)))
+	// The delete route goes through noSurf like every other dynamic route so
+	// it's protected against CSRF.
+	mux.Post("/snippet/:id/delete", dynamicMiddleware.Append(app.requireAuthentication).Then(http.HandlerFunc(app.deleteSnippet)))
+	mux.Post("/snippet/:id/unlock", dynamicMiddleware.Then(http.HandlerFunc(app.unlockSnippet)))
+	mux.Get("/snippet/:id/history", dynamicMiddleware.Then(http.HandlerFunc(app.snippetHistory)))
+	mux.Get("/snippet/:id/diff", dynamicMiddleware.Then(http.HandlerFunc(app.snippetDiff)))
+	mux.Get("/snippet/:id/raw", dynamicMiddleware.Then(http.HandlerFunc(app.rawSnippet)))
+	mux.Get("/snippet/:id/download", dynamicMiddleware.Then(http.HandlerFunc(app.downloadSnippet)))
 	mux.Get("/snippet/:id", dynamicMiddleware.Then(http.HandlerFunc(app.showSnippet)))
+	// Unlisted snippets are shared with a random slug instead of the id
+	mux.Post("/s/:slug/unlock", dynamicMiddleware.Then(http.HandlerFunc(app.unlockSnippet)))
+	mux.Get("/s/:slug/fork", dynamicMiddleware.Append(app.requireAuthentication).Then(http.HandlerFunc(app.forkSnippetForm)))
+	mux.Get("/s/:slug/history", dynamicMiddleware.Then(http.HandlerFunc(app.snippetHistory)))
+	mux.Get("/s/:slug/diff", dynamicMiddleware.Then(http.HandlerFunc(app.snippetDiff)))
+	mux.Get("/s/:slug/raw", dynamicMiddleware.Then(http.HandlerFunc(app.rawSnippet)))
+	mux.Get("/s/:slug/download", dynamicMiddleware.Then(http.HandlerFunc(app.downloadSnippet)))
+	mux.Get("/s/:slug", dynamicMiddleware.Then(http.HandlerFunc(app.showSnippet)))
 
 	// User signup, login and logout
 	mux.Get("/user/signup", dynamicMiddleware.Then(http.HandlerFunc(app.signupUserForm)))
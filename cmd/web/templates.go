@@ -1,11 +1,17 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/eiliz/snippetbox/pkg/diff"
 	"github.com/eiliz/snippetbox/pkg/forms"
+	"github.com/eiliz/snippetbox/pkg/highlight"
 	"github.com/eiliz/snippetbox/pkg/models"
 )
 
@@ -14,13 +20,28 @@ import (
 // This is needed because Go's html/template pkg accepts a single item of
 // dynamic data.
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            *forms.Form
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear         int
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	Parent              *models.Snippet
+	PrevPage            string
+	NextPage            string
+	Revisions           []*models.Revision
+	From                *models.Revision
+	To                  *models.Revision
+	Diff                []diff.Hunk
+	Markdown            template.HTML
+	DailyViews          []*models.DailyViews
+	MaxViews            int
+	Query               string
+	SearchTerms         []string
+	Tag                 string
+	Languages           []highlight.Language
+	Form                *forms.Form
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
 }
 
 // Returns the time in this format: "17 Dec 2020 at 10:00"
@@ -32,8 +53,118 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// countdown returns the time left until t in its largest whole unit, e.g.
+// "3 days" or "1 hour". It returns "" for the zero time and past times.
+func countdown(t time.Time) string {
+	d := time.Until(t)
+	if t.IsZero() || d <= 0 {
+		return ""
+	}
+
+	plural := func(n time.Duration, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	switch {
+	case d >= 24*time.Hour:
+		return plural(d/(24*time.Hour), "day")
+	case d >= time.Hour:
+		return plural(d/time.Hour, "hour")
+	case d >= time.Minute:
+		return plural(d/time.Minute, "minute")
+	default:
+		return "less than a minute"
+	}
+}
+
+// termsRegexp returns a case insensitive regexp matching any of the terms, or
+// nil when there are none.
+func termsRegexp(terms []string) *regexp.Regexp {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+// markTerms escapes s and wraps the occurrences of the terms in <mark> tags
+func markTerms(s string, terms []string) template.HTML {
+	re := termsRegexp(terms)
+	if re == nil {
+		return template.HTML(template.HTMLEscapeString(s))
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		b.WriteString(template.HTMLEscapeString(s[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(s[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(s[last:]))
+
+	return template.HTML(b.String())
+}
+
+// excerpt returns about n characters of s around the first occurrence of the
+// terms, or from the start when none of them is found.
+func excerpt(s string, terms []string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	start := 0
+	if re := termsRegexp(terms); re != nil {
+		if loc := re.FindStringIndex(s); loc != nil {
+			// Leave a bit of context before the match
+			start = utf8.RuneCountInString(s[:loc[0]]) - n/4
+			if start < 0 {
+				start = 0
+			}
+		}
+	}
+
+	end := start + n
+	if end > len(runes) {
+		end, start = len(runes), len(runes)-n
+	}
+
+	out := string(runes[start:end])
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(runes) {
+		out += "…"
+	}
+
+	return out
+}
+
+// Returns a ratio between 0 and 1 as a rounded percentage, e.g. "85%"
+func percent(f float64) string {
+	return fmt.Sprintf("%.0f%%", f*100)
+}
+
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"countdown": countdown,
+	"markTerms": markTerms,
+	"excerpt":   excerpt,
+	"percent":   percent,
+	// Snippet content, highlighted for its language
+	"highlightCode": highlight.HTML,
+	"languageLabel": highlight.Label,
 }
 
 func newTemplateCache(dir string) (map[string]*template.Template, error) {
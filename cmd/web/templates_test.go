@@ -1,6 +1,7 @@
 package main
 
 import (
+	"html/template"
 	"testing"
 	"time"
 )
@@ -37,3 +38,74 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestMarkTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		terms []string
+		want  template.HTML
+	}{
+		{"No terms", "<b>Pond</b>", nil, "&lt;b&gt;Pond&lt;/b&gt;"},
+		{"Case insensitive", "Old pond, POND", []string{"pond"}, "Old <mark>pond</mark>, <mark>POND</mark>"},
+		{"Escaped around", "a < frog > b", []string{"frog"}, "a &lt; <mark>frog</mark> &gt; b"},
+		{"Several terms", "old silent pond", []string{"pond", "old"}, "<mark>old</mark> silent <mark>pond</mark>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markTerms(tt.s, tt.terms); got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		terms []string
+		want  string
+	}{
+		{"Short", "Old pond", []string{"pond"}, "Old pond"},
+		{"No match", "abcdefghijklmnop", []string{"z"}, "abcdefgh…"},
+		{"Around the match", "abcdefghijklmnop", []string{"ij"}, "…ghijklmn…"},
+		{"At the end", "abcdefghijklmnop", []string{"op"}, "…ijklmnop"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := excerpt(tt.s, tt.terms, 8); got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	// A few seconds more so the units don't round down while the test runs
+	now := time.Now().Add(5 * time.Second)
+
+	tests := []struct {
+		name string
+		tm   time.Time
+		want string
+	}{
+		{"Days", now.Add(3*24*time.Hour + 5*time.Hour), "3 days"},
+		{"One day", now.Add(24 * time.Hour), "1 day"},
+		{"Hours", now.Add(5 * time.Hour), "5 hours"},
+		{"Minutes", now.Add(2 * time.Minute), "2 minutes"},
+		{"Seconds", now, "less than a minute"},
+		{"Past", now.Add(-time.Hour), ""},
+		{"Never", time.Time{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countdown(tt.tm); got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
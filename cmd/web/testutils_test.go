@@ -1,22 +1,74 @@
 package main
 
 import (
+	"html"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
 	"testing"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+	"github.com/eiliz/snippetbox/pkg/models/memory"
+	"github.com/golangcollege/sessions"
 )
 
+// Matches the value of the hidden csrf_token input rendered in our forms.
+var csrfTokenRX = regexp.MustCompile(`<input type="hidden" name="csrf_token" value="(.+)">`)
+
+func extractCSRFToken(t *testing.T, body []byte) string {
+	matches := csrfTokenRX.FindSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no csrf token found in body")
+	}
+
+	// html/template escapes the + and / characters of the base64 token so we
+	// need to unescape it before sending it back.
+	return html.UnescapeString(string(matches[1]))
+}
+
 type testServer struct {
 	*httptest.Server
 }
 
 func newTestApplication(t *testing.T) *application {
+	templateCache, err := newTemplateCache("./../../ui/html/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := sessions.New([]byte("3dSm5MnygFHh7XidAtbskXrjbwfoJcbJ"))
+	session.Lifetime = 12 * time.Hour
+	session.Secure = true
+
+	// Seed the in-memory stores with a user (ID 1) and a snippet they own (ID 1)
+	// that the tests can rely on.
+	users := memory.NewUserModel()
+	err = users.Insert("Alice", "alice@example.com", "validPa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippets := memory.NewSnippetModel(users)
+	_, err = snippets.Insert(&models.Snippet{UserID: 1, Title: "An old silent pond", Content: "An old silent pond...", Expires: time.Now().AddDate(0, 0, 7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	return &application{
-		errorLog: log.New(ioutil.Discard, "", 0),
-		infoLog:  log.New(ioutil.Discard, "", 0),
+		errorLog:      log.New(ioutil.Discard, "", 0),
+		infoLog:       log.New(ioutil.Discard, "", 0),
+		session:       session,
+		snippets:      snippets,
+		users:         users,
+		templateCache: templateCache,
+		views:         newViewCounter(),
+		markdown:      newMarkdownCache(),
+		unlocks:       newUnlockLimiter(),
 	}
 }
 
@@ -56,3 +108,36 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, []byt
 
 	return rs.StatusCode, rs.Header, body
 }
+
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, []byte) {
+	rs, err := ts.Client().PostForm(ts.URL+urlPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	body, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, body
+}
+
+// login signs in the seeded user alice@example.com and returns a fresh CSRF
+// token that can be used for subsequent POST requests.
+func (ts *testServer) login(t *testing.T) string {
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login: want %d, got %d", http.StatusSeeOther, code)
+	}
+
+	_, _, body = ts.get(t, "/snippet/create")
+	return extractCSRFToken(t, body)
+}
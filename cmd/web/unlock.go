@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+)
+
+// How long the passwords tried for a snippet are remembered
+const unlockWindow = 15 * time.Minute
+
+// The attempts of a session, or of every session together when session is
+// empty
+type unlockKey struct {
+	snippetID int
+	session   string
+}
+
+type unlockAttempts struct {
+	count int
+	first time.Time
+}
+
+// unlockLimiter counts the passwords tried for each snippet by each session,
+// and by every session together. The counts are kept on the server so that
+// replaying a session cookie doesn't start over, and the looser limit of the
+// snippet bounds the guesses of clients that drop their cookie every time.
+type unlockLimiter struct {
+	mu       sync.Mutex
+	attempts map[unlockKey]*unlockAttempts
+}
+
+func newUnlockLimiter() *unlockLimiter {
+	return &unlockLimiter{attempts: map[unlockKey]*unlockAttempts{}}
+}
+
+// take counts an attempt before the password is checked, or reports false
+// when the session or the snippet already used up its attempts. Counting and
+// checking the limits together keeps concurrent requests from getting past
+// them while their passwords are being hashed.
+func (l *unlockLimiter) take(snippetID int, session string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The counts of the finished windows are dropped along the way
+	for k, a := range l.attempts {
+		if now.Sub(a.first) >= unlockWindow {
+			delete(l.attempts, k)
+		}
+	}
+
+	bySession := l.get(unlockKey{snippetID: snippetID, session: session}, now)
+	bySnippet := l.get(unlockKey{snippetID: snippetID}, now)
+	if bySession.count >= maxUnlockAttempts || bySnippet.count >= maxSnippetUnlockAttempts {
+		return false
+	}
+	bySession.count++
+	bySnippet.count++
+
+	return true
+}
+
+// get returns the attempts counted for a key, starting a window when there's
+// none
+func (l *unlockLimiter) get(key unlockKey, now time.Time) *unlockAttempts {
+	a, ok := l.attempts[key]
+	if !ok {
+		a = &unlockAttempts{first: now}
+		l.attempts[key] = a
+	}
+
+	return a
+}
+
+// reset forgets the attempts of a session once it gave the right password.
+// Its last attempt isn't held against the snippet either.
+func (l *unlockLimiter) reset(snippetID int, session string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, unlockKey{snippetID: snippetID, session: session})
+	if a, ok := l.attempts[unlockKey{snippetID: snippetID}]; ok && a.count > 0 {
+		a.count--
+	}
+}
+
+// unlockSession returns the random id the unlock attempts of the session are
+// counted under, picking one on the first attempt
+func (app *application) unlockSession(r *http.Request) (string, error) {
+	id := app.session.GetString(r, "unlockSession")
+	if id != "" {
+		return id, nil
+	}
+
+	id, err := models.NewSlug()
+	if err != nil {
+		return "", err
+	}
+	app.session.Put(r, "unlockSession", id)
+
+	return id, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUnlockLimiterTake(t *testing.T) {
+	l := newUnlockLimiter()
+	now := time.Now()
+
+	// Concurrent attempts can't get past the limit while their passwords are
+	// being checked
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	taken := 0
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l.take(1, "alice", now) {
+				mu.Lock()
+				taken++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if taken != maxUnlockAttempts {
+		t.Errorf("want %d attempts taken, got %d", maxUnlockAttempts, taken)
+	}
+
+	if !l.take(1, "bob", now) || !l.take(2, "alice", now) {
+		t.Errorf("want the attempts counted per session and snippet")
+	}
+
+	l.reset(1, "alice")
+	if !l.take(1, "alice", now) {
+		t.Errorf("want the attempts forgotten after a reset")
+	}
+
+	if !l.take(1, "alice", now.Add(unlockWindow)) {
+		t.Errorf("want the attempts forgotten after the window")
+	}
+}
+
+func TestUnlockLimiterSnippetLimit(t *testing.T) {
+	l := newUnlockLimiter()
+	now := time.Now()
+
+	// A client dropping its session every time still runs out of attempts
+	for i := 0; i < maxSnippetUnlockAttempts; i++ {
+		if !l.take(1, strconv.Itoa(i), now) {
+			t.Fatalf("want attempt %d taken", i)
+		}
+	}
+
+	if l.take(1, "new", now) {
+		t.Errorf("want the attempts of the snippet used up")
+	}
+
+	if !l.take(2, "new", now) {
+		t.Errorf("want the other snippets left alone")
+	}
+}
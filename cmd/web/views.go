@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+)
+
+// How many days of views the stats page shows
+const statsDays = 30
+
+type viewKey struct {
+	snippetID int
+	day       time.Time
+}
+
+// viewCounter counts the snippet views in memory so that serving a snippet
+// doesn't write to the database. The counts are added to the store in batches
+// by flushViews.
+type viewCounter struct {
+	mu     sync.Mutex
+	counts map[viewKey]*models.ViewCount
+}
+
+func newViewCounter() *viewCounter {
+	return &viewCounter{counts: map[viewKey]*models.ViewCount{}}
+}
+
+// add counts a view of a snippet at the given time
+func (c *viewCounter) add(snippetID int, t time.Time) {
+	t = t.UTC()
+	key := viewKey{snippetID: snippetID, day: t.Truncate(24 * time.Hour)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	vc, ok := c.counts[key]
+	if !ok {
+		vc = &models.ViewCount{SnippetID: snippetID, Day: key.day}
+		c.counts[key] = vc
+	}
+
+	vc.Views++
+	if t.After(vc.LastViewed) {
+		vc.LastViewed = t
+	}
+}
+
+// pending returns copies of the counts of a snippet that weren't flushed yet
+func (c *viewCounter) pending(snippetID int) []*models.ViewCount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := []*models.ViewCount{}
+	for key, vc := range c.counts {
+		if key.snippetID == snippetID {
+			copied := *vc
+			counts = append(counts, &copied)
+		}
+	}
+
+	return counts
+}
+
+// take returns the views counted so far and starts counting from zero
+func (c *viewCounter) take() []*models.ViewCount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make([]*models.ViewCount, 0, len(c.counts))
+	for _, vc := range c.counts {
+		counts = append(counts, vc)
+	}
+	c.counts = map[viewKey]*models.ViewCount{}
+
+	return counts
+}
+
+// restore puts back views that couldn't be flushed, so that the next flush
+// tries again
+func (c *viewCounter) restore(counts []*models.ViewCount) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, vc := range counts {
+		key := viewKey{snippetID: vc.SnippetID, day: vc.Day}
+
+		current, ok := c.counts[key]
+		if !ok {
+			c.counts[key] = vc
+			continue
+		}
+
+		current.Views += vc.Views
+		if vc.LastViewed.After(current.LastViewed) {
+			current.LastViewed = vc.LastViewed
+		}
+	}
+}
+
+// addPendingViews adds the views that weren't flushed yet to the total of the
+// snippet, and returns them
+func (app *application) addPendingViews(s *models.Snippet) []*models.ViewCount {
+	counts := app.views.pending(s.ID)
+	for _, vc := range counts {
+		s.Views += vc.Views
+		if vc.LastViewed.After(s.LastViewed) {
+			s.LastViewed = vc.LastViewed
+		}
+	}
+
+	return counts
+}
+
+// flushViews adds the counted views to the snippets every interval until the
+// context is cancelled
+func (app *application) flushViews(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.flushViewsOnce()
+		}
+	}
+}
+
+// flushViewsOnce adds the views counted since the last flush to the snippets
+func (app *application) flushViewsOnce() {
+	counts := app.views.take()
+	if len(counts) == 0 {
+		return
+	}
+
+	if err := app.snippets.AddViews(counts); err != nil {
+		app.errorLog.Printf("flushing snippet views: %s", err)
+		app.views.restore(counts)
+	}
+}
@@ -0,0 +1,275 @@
+// Package detect guesses the programming language of a snippet. It looks for
+// a shebang line first, then for a file name in the title like main.go, and
+// falls back to counting language specific keywords in the content. The names
+// it returns are the ones of the highlight package.
+package detect
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Confidence of the detection by the strongest clues. Keyword counting never
+// goes above keywordConfidence.
+const (
+	shebangConfidence   = 0.95
+	extensionConfidence = 0.9
+	jsonConfidence      = 0.9
+	keywordConfidence   = 0.8
+)
+
+// The minimum keyword score and share of the total score for a language to be
+// picked, below that the snippet is left as plain text.
+const (
+	minScore = 4
+	minShare = 0.4
+)
+
+// A pattern is only counted this many times so a single very common token
+// can't outweigh everything else.
+const maxMatches = 5
+
+var interpreters = map[string]string{
+	"bash":    "bash",
+	"dash":    "bash",
+	"ksh":     "bash",
+	"sh":      "bash",
+	"zsh":     "bash",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"ts-node": "typescript",
+	"php":     "php",
+	"python":  "python",
+	"ruby":    "ruby",
+}
+
+var extensions = map[string]string{
+	".bash": "bash",
+	".c":    "c",
+	".cc":   "cpp",
+	".cpp":  "cpp",
+	".css":  "css",
+	".cxx":  "cpp",
+	".go":   "go",
+	".h":    "c",
+	".hpp":  "cpp",
+	".htm":  "html",
+	".html": "html",
+	".java": "java",
+	".js":   "javascript",
+	".json": "json",
+	".mjs":  "javascript",
+	".php":  "php",
+	".py":   "python",
+	".rb":   "ruby",
+	".rs":   "rust",
+	".sh":   "bash",
+	".sql":  "sql",
+	".ts":   "typescript",
+	".tsx":  "typescript",
+	".yaml": "yaml",
+	".yml":  "yaml",
+}
+
+type rule struct {
+	re     *regexp.Regexp
+	weight int
+}
+
+// Strong hints weigh 3, tokens that other languages share weigh 1.
+var keywords = map[string][]rule{
+	"bash": {
+		{regexp.MustCompile(`(?m)^\s*(if|while) \[\[? `), 3},
+		{regexp.MustCompile(`(?m)^\s*(fi|done|esac)\s*$`), 3},
+		{regexp.MustCompile(`(?m)^\s*echo\b`), 1},
+		{regexp.MustCompile(`\$\{\w+`), 1},
+		{regexp.MustCompile(`(?m)^\s*export \w+=`), 3},
+	},
+	"c": {
+		{regexp.MustCompile(`(?m)^#include <\w+\.h>`), 3},
+		{regexp.MustCompile(`\bint main\(`), 1},
+		{regexp.MustCompile(`\b(printf|malloc|free)\(`), 1},
+		{regexp.MustCompile(`\bstruct \w+ \{`), 1},
+	},
+	"cpp": {
+		{regexp.MustCompile(`(?m)^#include <\w+>`), 3},
+		{regexp.MustCompile(`\bstd::`), 3},
+		{regexp.MustCompile(`\b(cout|cin) <<|>>`), 1},
+		{regexp.MustCompile(`\btemplate\s*<`), 3},
+		{regexp.MustCompile(`\bnamespace \w+`), 1},
+	},
+	"css": {
+		{regexp.MustCompile(`(?m)^\s*[.#]?[\w-]+(\s*,\s*[.#]?[\w-]+)*\s*\{\s*$`), 1},
+		{regexp.MustCompile(`(?m)^\s*[a-z-]+\s*:\s*[^;{}]+;\s*$`), 1},
+		{regexp.MustCompile(`@media\b`), 3},
+	},
+	"go": {
+		{regexp.MustCompile(`(?m)^package \w+\s*$`), 3},
+		{regexp.MustCompile(`\bfunc\b`), 1},
+		{regexp.MustCompile(`:=`), 1},
+		{regexp.MustCompile(`\bfmt\.`), 3},
+		{regexp.MustCompile(`\berr != nil\b`), 3},
+		{regexp.MustCompile(`\b(defer|chan)\b`), 1},
+	},
+	"html": {
+		{regexp.MustCompile(`(?i)<!doctype html`), 3},
+		{regexp.MustCompile(`(?i)</(html|head|body|div|span|p|a|ul|li|table)>`), 1},
+	},
+	"java": {
+		{regexp.MustCompile(`\bpublic (final )?class\b`), 3},
+		{regexp.MustCompile(`\bSystem\.out\.print`), 3},
+		{regexp.MustCompile(`(?m)^import java\.`), 3},
+		{regexp.MustCompile(`@Override\b`), 3},
+		{regexp.MustCompile(`\b(public|private|protected) \w+ \w+\(`), 1},
+	},
+	"javascript": {
+		{regexp.MustCompile(`\b(const|let) \w+ = `), 1},
+		{regexp.MustCompile(`\bfunction\b`), 1},
+		{regexp.MustCompile(`=>`), 1},
+		{regexp.MustCompile(`\bconsole\.log\(`), 3},
+		{regexp.MustCompile(`\b(document|window)\.`), 3},
+		{regexp.MustCompile(`\brequire\(['"]`), 3},
+	},
+	"php": {
+		{regexp.MustCompile(`<\?php`), 3},
+		{regexp.MustCompile(`\$\w+\s*=`), 1},
+		{regexp.MustCompile(`\$this->`), 3},
+		{regexp.MustCompile(`\becho\b`), 1},
+	},
+	"python": {
+		{regexp.MustCompile(`(?m)^\s*def \w+\(.*\):\s*$`), 3},
+		{regexp.MustCompile(`(?m)^from [\w.]+ import\b`), 3},
+		{regexp.MustCompile(`(?m)^import \w+\s*$`), 1},
+		{regexp.MustCompile(`\bself\.`), 1},
+		{regexp.MustCompile(`\b(elif|None|True|False)\b`), 1},
+		{regexp.MustCompile(`__name__`), 3},
+	},
+	"ruby": {
+		{regexp.MustCompile(`(?m)^\s*def \w+[?!]?\s*$`), 3},
+		{regexp.MustCompile(`(?m)^\s*end\s*$`), 1},
+		{regexp.MustCompile(`\bputs\b`), 1},
+		{regexp.MustCompile(`\bdo \|\w+(, \w+)*\|`), 3},
+		{regexp.MustCompile(`(?m)^require '[\w/]+'`), 3},
+	},
+	"rust": {
+		{regexp.MustCompile(`\bfn \w+`), 1},
+		{regexp.MustCompile(`\blet mut\b`), 3},
+		{regexp.MustCompile(`\bprintln!\(`), 3},
+		{regexp.MustCompile(`\bimpl\b`), 1},
+		{regexp.MustCompile(`\buse \w+::`), 3},
+	},
+	"sql": {
+		{regexp.MustCompile(`(?is)\bSELECT\b.+?\bFROM\b`), 3},
+		{regexp.MustCompile(`(?i)\bINSERT INTO\b`), 3},
+		{regexp.MustCompile(`(?i)\bCREATE (TABLE|INDEX)\b`), 3},
+		{regexp.MustCompile(`(?i)\bWHERE\b`), 1},
+	},
+	"typescript": {
+		{regexp.MustCompile(`\w\??:\s*(string|number|boolean|any|void)\b`), 3},
+		{regexp.MustCompile(`\binterface \w+\s*\{`), 3},
+		{regexp.MustCompile(`\btype \w+\s*=`), 1},
+		{regexp.MustCompile(`\b(const|let) \w+ = `), 1},
+		{regexp.MustCompile(`=>`), 1},
+	},
+	"yaml": {
+		{regexp.MustCompile(`(?m)^---\s*$`), 1},
+		{regexp.MustCompile(`(?m)^\s*[\w-]+:(\s+\S.*)?$`), 1},
+		{regexp.MustCompile(`(?m)^\s*- [\w"']`), 1},
+	},
+}
+
+// Language returns the most likely language of a snippet and how confident
+// the guess is, between 0 and 1. It returns an empty language and 0 when
+// there isn't enough to go on.
+func Language(title, content string) (string, float64) {
+	if lang := fromShebang(content); lang != "" {
+		return lang, shebangConfidence
+	}
+
+	if lang := fromTitle(title); lang != "" {
+		return lang, extensionConfidence
+	}
+
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		if json.Valid([]byte(trimmed)) {
+			return "json", jsonConfidence
+		}
+	}
+
+	return fromKeywords(content)
+}
+
+// fromShebang returns the language of the interpreter named on a #! first
+// line, like #!/bin/sh or #!/usr/bin/env python3.
+func fromShebang(content string) string {
+	line := strings.SplitN(content, "\n", 2)[0]
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	name := path.Base(fields[0])
+	if name == "env" {
+		// Skip the options of env, e.g. #!/usr/bin/env -S node --harmony
+		name = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				name = f
+				break
+			}
+		}
+	}
+
+	// python3.9 is python
+	name = strings.TrimRight(name, "0123456789.")
+
+	return interpreters[name]
+}
+
+// fromTitle returns the language of a file name ending the title, like in
+// "Hello world in main.go".
+func fromTitle(title string) string {
+	fields := strings.Fields(title)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return extensions[strings.ToLower(path.Ext(fields[len(fields)-1]))]
+}
+
+// fromKeywords scores every language on the patterns found in the content and
+// returns the best one if it stands out enough.
+func fromKeywords(content string) (string, float64) {
+	best, bestScore, total := "", 0, 0
+	for lang, rules := range keywords {
+		score := 0
+		for _, r := range rules {
+			score += r.weight * len(r.re.FindAllStringIndex(content, maxMatches))
+		}
+
+		total += score
+		// Break ties on the name so the result doesn't depend on the map
+		// iteration order.
+		if score > bestScore || (score == bestScore && lang < best) {
+			best, bestScore = lang, score
+		}
+	}
+
+	if bestScore < minScore {
+		return "", 0
+	}
+
+	share := float64(bestScore) / float64(total)
+	if share < minShare {
+		return "", 0
+	}
+
+	return best, share * keywordConfidence
+}
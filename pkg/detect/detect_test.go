@@ -0,0 +1,85 @@
+package detect
+
+import (
+	"testing"
+
+	"github.com/eiliz/snippetbox/pkg/highlight"
+)
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		content  string
+		wantLang string
+	}{
+		{"Shebang", "Backup", "#!/bin/sh\ntar czf backup.tgz .", "bash"},
+		{"Shebang with env", "Script", "#!/usr/bin/env python3\nprint(1)", "python"},
+		{"Unknown interpreter", "Script", "#!/usr/bin/awk -f\n{ print }", ""},
+		{"File name in title", "Hello world in main.go", "whatever", "go"},
+		{"Extension case", "Build.SQL", "whatever", "sql"},
+		{"Not a file name", "Version 1.2", "", ""},
+		{"JSON", "Config", `{"debug": true, "port": 4000}`, "json"},
+		{
+			"Go keywords", "Hello",
+			"package main\n\nimport \"fmt\"\n\nfunc main() {\n\tmsg := \"hi\"\n\tfmt.Println(msg)\n}",
+			"go",
+		},
+		{
+			"Python keywords", "Fibonacci",
+			"def fib(n):\n    if n < 2:\n        return n\n    return fib(n-1) + fib(n-2)\n\nif __name__ == '__main__':\n    print(fib(10))",
+			"python",
+		},
+		{
+			"SQL keywords", "Users",
+			"SELECT id, name\nFROM users\nWHERE active = true;",
+			"sql",
+		},
+		{
+			"Java keywords", "Hello",
+			"public class Hello {\n    public static void main(String[] args) {\n        System.out.println(\"Hello\");\n    }\n}",
+			"java",
+		},
+		{"Prose", "A haiku", "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.", ""},
+		{"Empty", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lang, confidence := Language(tt.title, tt.content)
+
+			if lang != tt.wantLang {
+				t.Errorf("want %q, got %q", tt.wantLang, lang)
+			}
+
+			if (lang == "") != (confidence == 0) || confidence < 0 || confidence > 1 {
+				t.Errorf("want a confidence between 0 and 1 only with a language, got %v", confidence)
+			}
+		})
+	}
+}
+
+// Every language the package can return must be one that can be highlighted.
+func TestLanguagesAreSupported(t *testing.T) {
+	supported := map[string]bool{}
+	for _, name := range highlight.Names() {
+		supported[name] = true
+	}
+
+	names := []string{}
+	for _, lang := range interpreters {
+		names = append(names, lang)
+	}
+	for _, lang := range extensions {
+		names = append(names, lang)
+	}
+	for lang := range keywords {
+		names = append(names, lang)
+	}
+
+	for _, name := range append(names, "json") {
+		if !supported[name] {
+			t.Errorf("%q can't be highlighted", name)
+		}
+	}
+}
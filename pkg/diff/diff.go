@@ -0,0 +1,313 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op tells what happened to a line between the old and the new text.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Line is a single line of a diff.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Prefix returns the marker used for the line in the unified format.
+func (l Line) Prefix() string {
+	switch l.Op {
+	case Insert:
+		return "+"
+	case Delete:
+		return "-"
+	default:
+		return " "
+	}
+}
+
+// Kind returns a name for the operation that can be used as a CSS class.
+func (l Line) Kind() string {
+	switch l.Op {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// Hunk is a group of changed lines surrounded by some unchanged context lines.
+// The starts are 1 based line numbers like in the output of diff -u.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []Line
+}
+
+// Header returns the "@@ -1,3 +1,4 @@" range line of the hunk.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// splitLines splits a text into lines, ignoring the difference between \n and
+// \r\n line endings and a trailing newline.
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return []string{}
+	}
+
+	return strings.Split(s, "\n")
+}
+
+// Lines returns the shortest edit script turning the lines of a into the lines
+// of b, using the linear space variant of Myers' algorithm: the middle snake
+// of the edit graph splits the texts in two halves that are compared in turn.
+// Within a run of changes the deleted lines come before the inserted ones.
+func Lines(a, b string) []Line {
+	x, y := splitLines(a), splitLines(b)
+
+	// Comparing numbers is cheaper than comparing lines
+	ids := map[string]int{}
+	intern := func(lines []string) []int {
+		ints := make([]int, len(lines))
+		for i, l := range lines {
+			id, ok := ids[l]
+			if !ok {
+				id = len(ids)
+				ids[l] = id
+			}
+			ints[i] = id
+		}
+		return ints
+	}
+
+	d := differ{x: x, y: y, a: intern(x), b: intern(y)}
+	d.compare(0, len(x), 0, len(y))
+
+	return groupChanges(d.lines)
+}
+
+// differ collects the edit script of the lines x and y, which are a and b as
+// numbers.
+type differ struct {
+	x, y  []string
+	a, b  []int
+	lines []Line
+}
+
+// compare appends the edit script turning a[aLo:aHi] into b[bLo:bHi]
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	// The common prefix and suffix are left out of the search
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.lines = append(d.lines, Line{Equal, d.x[aLo]})
+		aLo++
+		bLo++
+	}
+
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-suffix-1] == d.b[bHi-suffix-1] {
+		suffix++
+	}
+	aHi, bHi = aHi-suffix, bHi-suffix
+
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.lines = append(d.lines, Line{Insert, d.y[j]})
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.lines = append(d.lines, Line{Delete, d.x[i]})
+		}
+	default:
+		// Without a common prefix or suffix there are at least two edits, so
+		// the split point is strictly inside and both halves are smaller.
+		i, j := d.split(aLo, aHi, bLo, bHi)
+		d.compare(aLo, i, bLo, j)
+		d.compare(i, aHi, j, bHi)
+	}
+
+	for i := aHi; i < aHi+suffix; i++ {
+		d.lines = append(d.lines, Line{Equal, d.x[i]})
+	}
+}
+
+// split finds the middle snake of the shortest path from (aLo, bLo) to (aHi,
+// bHi) by searching forward from the start and backward from the end at the
+// same time, and returns the point where the paths meet. vf[k] and vb[k] hold
+// the furthest distance reached on diagonal k from the start and from the end.
+func (d *differ) split(aLo, aHi, bLo, bHi int) (int, int) {
+	n, m := aHi-aLo, bHi-bLo
+	max := (n + m + 1) / 2
+	offset := max + 1
+	vf := make([]int, 2*max+3)
+	vb := make([]int, 2*max+3)
+	for k := range vf {
+		vf[k], vb[k] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	// When the difference of lengths is odd the paths can only meet during a
+	// forward step, and during a backward step otherwise.
+	delta := n - m
+	forward := delta%2 != 0
+
+	for e := 0; e <= max; e++ {
+		for k := -e; k <= e; k += 2 {
+			var x int
+			if k == -e || (k != e && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+
+			if x > n || y > m || !forward {
+				continue
+			}
+			if kb := offset + delta - k; kb >= 0 && kb < len(vb) && vb[kb] != -1 && x >= n-vb[kb] {
+				return aLo + x, bLo + y
+			}
+		}
+
+		for k := -e; k <= e; k += 2 {
+			var x int
+			if k == -e || (k != e && vb[offset+k-1] < vb[offset+k+1]) {
+				x = vb[offset+k+1]
+			} else {
+				x = vb[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aHi-x-1] == d.b[bHi-y-1] {
+				x++
+				y++
+			}
+			vb[offset+k] = x
+
+			if x > n || y > m || forward {
+				continue
+			}
+			if kf := offset + delta - k; kf >= 0 && kf < len(vf) && vf[kf] != -1 && vf[kf] >= n-x {
+				xf := vf[kf]
+				return aLo + xf, bLo + xf - (kf - offset)
+			}
+		}
+	}
+
+	// The paths always meet within max steps, this is only a safe answer
+	return aHi, bLo
+}
+
+// groupChanges moves the deletions of every run of changes before its
+// insertions, like diff -u shows them
+func groupChanges(lines []Line) []Line {
+	grouped := make([]Line, 0, len(lines))
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			grouped = append(grouped, lines[i])
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(lines) && lines[end].Op != Equal {
+			end++
+		}
+		for _, op := range []Op{Delete, Insert} {
+			for _, l := range lines[i:end] {
+				if l.Op == op {
+					grouped = append(grouped, l)
+				}
+			}
+		}
+		i = end
+	}
+
+	return grouped
+}
+
+// Unified returns the changes between a and b grouped in hunks with the given
+// number of context lines, like diff -u does. It returns no hunks when the
+// texts are the same.
+func Unified(a, b string, context int) []Hunk {
+	lines := Lines(a, b)
+
+	// Find the ranges of lines to show: every change plus its context, merging
+	// ranges that touch or overlap.
+	type span struct{ start, end int }
+	spans := []span{}
+	for i, l := range lines {
+		if l.Op == Equal {
+			continue
+		}
+
+		start, end := i-context, i+context+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		if n := len(spans); n > 0 && start <= spans[n-1].end {
+			spans[n-1].end = end
+		} else {
+			spans = append(spans, span{start, end})
+		}
+	}
+
+	hunks := []Hunk{}
+	oldNo, newNo, pos := 0, 0, 0
+	for _, s := range spans {
+		// Count the lines skipped since the previous hunk.
+		for ; pos < s.start; pos++ {
+			oldNo++
+			newNo++
+		}
+
+		h := Hunk{OldStart: oldNo + 1, NewStart: newNo + 1, Lines: lines[s.start:s.end]}
+		for ; pos < s.end; pos++ {
+			switch lines[pos].Op {
+			case Equal:
+				oldNo++
+				newNo++
+				h.OldLines++
+				h.NewLines++
+			case Delete:
+				oldNo++
+				h.OldLines++
+			case Insert:
+				newNo++
+				h.NewLines++
+			}
+		}
+
+		// An empty range points at the line before it, e.g. -0,0 for a file
+		// that was created.
+		if h.OldLines == 0 {
+			h.OldStart--
+		}
+		if h.NewLines == 0 {
+			h.NewStart--
+		}
+
+		hunks = append(hunks, h)
+	}
+
+	return hunks
+}
@@ -0,0 +1,169 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// render formats hunks like diff -u does, to make the expectations readable.
+func render(hunks []Hunk) string {
+	var b strings.Builder
+	for _, h := range hunks {
+		b.WriteString(h.Header() + "\n")
+		for _, l := range h.Lines {
+			b.WriteString(l.Prefix() + l.Text + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	got := Lines("a\nb\nc\n", "a\nc\nd")
+	want := []Line{{Equal, "a"}, {Delete, "b"}, {Equal, "c"}, {Insert, "d"}}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+// lcs returns the length of the longest common subsequence of two lists of
+// lines, the number of unchanged lines of a shortest edit script
+func lcs(x, y []string) int {
+	prev, cur := make([]int, len(y)+1), make([]int, len(y)+1)
+	for i := range x {
+		for j := range y {
+			switch {
+			case x[i] == y[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(y)]
+}
+
+func TestLinesShortest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func() string {
+		lines := make([]string, r.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + r.Intn(4)))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := random(), random()
+		lines := Lines(a, b)
+
+		// The script must turn a into b
+		var from, to []string
+		equal := 0
+		for _, l := range lines {
+			if l.Op != Insert {
+				from = append(from, l.Text)
+			}
+			if l.Op != Delete {
+				to = append(to, l.Text)
+			}
+			if l.Op == Equal {
+				equal++
+			}
+		}
+
+		if strings.Join(from, "\n") != a || strings.Join(to, "\n") != b {
+			t.Fatalf("%q to %q: the script %v doesn't match the texts", a, b, lines)
+		}
+
+		if want := lcs(splitLines(a), splitLines(b)); equal != want {
+			t.Fatalf("%q to %q: want %d unchanged lines, got %d", a, b, want, equal)
+		}
+	}
+}
+
+func TestLinesLarge(t *testing.T) {
+	// Two unrelated revisions are the worst case of the search
+	a, b := make([]string, 5000), make([]string, 5000)
+	for i := range a {
+		a[i], b[i] = fmt.Sprintf("old line %d", i), fmt.Sprintf("new line %d", i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	lines := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+	runtime.ReadMemStats(&after)
+
+	if len(lines) != 10000 {
+		t.Errorf("want 10000 lines, got %d", len(lines))
+	}
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Errorf("want less than 64MiB allocated, got %dMiB", allocated>>20)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "Same",
+			a:    "a\nb",
+			b:    "a\r\nb\r\n",
+			want: "",
+		},
+		{
+			name: "Created",
+			a:    "",
+			b:    "a\nb",
+			want: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "Deleted",
+			a:    "a\nb",
+			b:    "",
+			want: "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "Changed line",
+			a:    "1\n2\n3\n4\n5",
+			b:    "1\n2\nthree\n4\n5",
+			want: "@@ -2,3 +2,3 @@\n 2\n-3\n+three\n 4\n",
+		},
+		{
+			name: "Two hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8",
+			b:    "one\n2\n3\n4\n5\n6\n7\neight",
+			want: "@@ -1,2 +1,2 @@\n-1\n+one\n 2\n@@ -7,2 +7,2 @@\n 7\n-8\n+eight\n",
+		},
+		{
+			name: "Merged hunks",
+			a:    "1\n2\n3\n4",
+			b:    "one\n2\n3\nfour",
+			want: "@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n-4\n+four\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := render(Unified(tt.a, tt.b, 1))
+
+			if got != tt.want {
+				t.Errorf("want\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
@@ -2,12 +2,21 @@ package forms
 
 import (
 	"fmt"
+	"math"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
+// The layout of the values of datetime-local inputs
+const DateTimeLayout = "2006-01-02T15:04"
+
+// Tags are short words of lowercase letters, digits, dashes and underscores
+var tagRX = regexp.MustCompile("^[a-z0-9][a-z0-9_-]{0,29}$")
+
 var emailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
 // The Form type annonymously embeds a url.Values object to hold form data, as
@@ -56,6 +65,15 @@ func (f *Form) MaxLength(field string, d int) {
 	}
 }
 
+// MaxBytes checks that a field is at most d bytes long, unlike MaxLength which
+// counts characters. It's for the values whose size in bytes is limited, like
+// the passwords bcrypt hashes.
+func (f *Form) MaxBytes(field string, d int) {
+	if len(f.Get(field)) > d {
+		f.Errors.Add(field, fmt.Sprintf("This field's value is too long. It must have a maximum length of %d bytes", d))
+	}
+}
+
 func (f *Form) PermittedValues(field string, opts ...string) {
 	value := f.Get(field)
 	if value == "" {
@@ -89,3 +107,118 @@ func (f *Form) matchesPattern(field string, pattern *regexp.Regexp) {
 func (f *Form) Email(field string) {
 	f.matchesPattern(field, emailRX)
 }
+
+// List returns the comma separated items of a field, trimmed, without the
+// empty ones and the duplicates.
+func (f *Form) List(field string) []string {
+	items := []string{}
+	seen := map[string]bool{}
+	for _, item := range strings.Split(f.Get(field), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// MaxItems checks that a comma separated field has at most d items
+func (f *Form) MaxItems(field string, d int) {
+	if len(f.List(field)) > d {
+		f.Errors.Add(field, fmt.Sprintf("This field can have at most %d items", d))
+	}
+}
+
+// Tags checks that every item of a comma separated field is a valid tag
+func (f *Form) Tags(field string) {
+	for _, tag := range f.List(field) {
+		if !tagRX.MatchString(tag) {
+			f.Errors.Add(field, fmt.Sprintf("The tag %q is invalid. Tags are up to 30 lowercase letters, digits, - and _", tag))
+			return
+		}
+	}
+}
+
+// The longest time.Duration, about 292 years
+const maxDuration = time.Duration(math.MaxInt64)
+
+// ParseDuration parses a duration like time.ParseDuration does, with an extra
+// d unit for days, e.g. "3h", "30d" or "1d12h".
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var days time.Duration
+	if i := strings.Index(value, "d"); i > 0 {
+		// Too many days would wrap around to a short or negative duration
+		n, err := strconv.Atoi(value[:i])
+		if err != nil || n < 0 || time.Duration(n) > maxDuration/(24*time.Hour) {
+			return 0, fmt.Errorf("forms: invalid duration %q", value)
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		value = value[i+1:]
+		if value == "" {
+			return days, nil
+		}
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d > maxDuration-days {
+		return 0, fmt.Errorf("forms: invalid duration %q", value)
+	}
+
+	return days + d, nil
+}
+
+// Duration checks that a field is a duration understood by ParseDuration
+// between min and max.
+func (f *Form) Duration(field string, min, max time.Duration) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+
+	d, err := ParseDuration(value)
+	if err != nil {
+		f.Errors.Add(field, "This field must be a duration like 3h or 30d")
+		return
+	}
+
+	if d < min || d > max {
+		f.Errors.Add(field, fmt.Sprintf("This field must be between %s and %s", formatDuration(min), formatDuration(max)))
+	}
+}
+
+// formatDuration formats a duration the way ParseDuration reads it, without
+// the zero minutes and seconds time.Duration.String adds
+func formatDuration(d time.Duration) string {
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+
+	s := strings.TrimSuffix(d.String(), "0s")
+	return strings.TrimSuffix(s, "0m")
+}
+
+// FutureTime checks that a field is a time in the DateTimeLayout, read in UTC,
+// which is still in the future but at most max from now.
+func (f *Form) FutureTime(field string, max time.Duration) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+
+	t, err := time.Parse(DateTimeLayout, value)
+	if err != nil {
+		f.Errors.Add(field, "This field must be a date and time")
+		return
+	}
+
+	now := time.Now()
+	if !t.After(now) {
+		f.Errors.Add(field, "This field must be in the future")
+	} else if t.After(now.Add(max)) {
+		f.Errors.Add(field, fmt.Sprintf("This field must be at most %s from now", formatDuration(max)))
+	}
+}
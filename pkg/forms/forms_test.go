@@ -0,0 +1,106 @@
+package forms
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"3h", 3 * time.Hour, false},
+		{"30d", 30 * 24 * time.Hour, false},
+		{"1d12h", 36 * time.Hour, false},
+		{" 90m ", 90 * time.Minute, false},
+		{"d", 0, true},
+		{"-1d", 0, true},
+		{"106751d", 106751 * 24 * time.Hour, false},
+		{"106752d", 0, true},
+		{"213503982334601d", 0, true},
+		{"106751d24h", 0, true},
+		{"3 days", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseDuration(tt.value)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("want %v (error %t), got %v (%v)", tt.want, tt.wantErr, got, err)
+			}
+		})
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		wantError string
+	}{
+		{"ASCII", strings.Repeat("a", 72), ""},
+		{"Too long", strings.Repeat("a", 73), "This field's value is too long. It must have a maximum length of 72 bytes"},
+		{"Multibyte", strings.Repeat("é", 72), "This field's value is too long. It must have a maximum length of 72 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(url.Values{"password": {tt.value}})
+			f.MaxBytes("password", 72)
+			if got := f.Errors.Get("password"); got != tt.wantError {
+				t.Errorf("want %q, got %q", tt.wantError, got)
+			}
+		})
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantError string
+	}{
+		{"3h", ""},
+		{"30s", "This field must be between 1m and 365d"},
+		{"400d", "This field must be between 1m and 365d"},
+		{"soon", "This field must be a duration like 3h or 30d"},
+		{"106752d", "This field must be a duration like 3h or 30d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			f := New(url.Values{"expires_in": {tt.value}})
+			f.Duration("expires_in", time.Minute, 365*24*time.Hour)
+			if got := f.Errors.Get("expires_in"); got != tt.wantError {
+				t.Errorf("want %q, got %q", tt.wantError, got)
+			}
+		})
+	}
+}
+
+func TestFutureTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		wantError string
+	}{
+		{"Future", time.Now().UTC().Add(time.Hour).Format(DateTimeLayout), ""},
+		{"Past", "2001-02-03T04:05", "This field must be in the future"},
+		{"Too far", "9999-12-31T23:59", "This field must be at most 30d from now"},
+		{"Invalid", "tomorrow", "This field must be a date and time"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(url.Values{"expires_at": {tt.value}})
+			f.FutureTime("expires_at", 30*24*time.Hour)
+			if got := f.Errors.Get("expires_at"); got != tt.wantError {
+				t.Errorf("want %q, got %q", tt.wantError, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Package highlight renders source code as HTML with syntax highlighting,
+// using the lexers of chroma. The colours are inlined in the markup so the
+// pages don't need any extra CSS or JavaScript.
+package highlight
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+)
+
+// Language is a programming language snippets can be written in
+type Language struct {
+	Name      string // the chroma lexer name, stored with the snippets
+	Label     string
+	Extension string // of the files snippets are downloaded as
+}
+
+// Languages lists the supported languages in the order they're offered in the
+// forms. An empty name stands for plain text.
+var Languages = []Language{
+	{"bash", "Bash", ".sh"},
+	{"c", "C", ".c"},
+	{"cpp", "C++", ".cpp"},
+	{"css", "CSS", ".css"},
+	{"go", "Go", ".go"},
+	{"html", "HTML", ".html"},
+	{"java", "Java", ".java"},
+	{"javascript", "JavaScript", ".js"},
+	{"json", "JSON", ".json"},
+	{"php", "PHP", ".php"},
+	{"python", "Python", ".py"},
+	{"ruby", "Ruby", ".rb"},
+	{"rust", "Rust", ".rs"},
+	{"sql", "SQL", ".sql"},
+	{"typescript", "TypeScript", ".ts"},
+	{"yaml", "YAML", ".yaml"},
+}
+
+// Names returns the names of the supported languages
+func Names() []string {
+	names := make([]string, len(Languages))
+	for i, l := range Languages {
+		names[i] = l.Name
+	}
+
+	return names
+}
+
+// Label returns the human readable name of a language, "Plain text" for an
+// empty or unknown one.
+func Label(name string) string {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l.Label
+		}
+	}
+
+	return "Plain text"
+}
+
+// Extension returns the file extension of a language, ".txt" for an empty or
+// unknown one.
+func Extension(name string) string {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l.Extension
+		}
+	}
+
+	return ".txt"
+}
+
+var formatter = html.New(html.TabWidth(4))
+
+var style = styles.Get("github")
+
+// HTML returns the code highlighted for the given language inside a <pre>
+// element. Unknown languages are rendered as plain text.
+func HTML(code, language string) (template.HTML, error) {
+	lexer := lexers.Get(language)
+	if language == "" || lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		return "", err
+	}
+
+	// The formatter escapes the tokens, the result is safe to include as is.
+	var buf bytes.Buffer
+	if err = formatter.Format(&buf, style, iterator); err != nil {
+		return "", err
+	}
+
+	return template.HTML(buf.String()), nil
+}
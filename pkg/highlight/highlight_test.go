@@ -0,0 +1,53 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/chroma/lexers"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		language string
+		want     []string
+	}{
+		{"Go", "func main() {}", "go", []string{"<pre", ">func</span>", ">main</span>"}},
+		{"Escaped", `x := "<b>"`, "go", []string{"&lt;b&gt;"}},
+		{"Plain text", "<b>func</b>", "", []string{"<pre", "&lt;b&gt;func&lt;/b&gt;"}},
+		{"Unknown language", "func", "cobol", []string{"<pre", "func"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HTML(tt.code, tt.language)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("want %q in %q", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestLabel(t *testing.T) {
+	for name, want := range map[string]string{"go": "Go", "cpp": "C++", "": "Plain text", "cobol": "Plain text"} {
+		if got := Label(name); got != want {
+			t.Errorf("%q: want %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestLanguagesHaveLexers(t *testing.T) {
+	for _, l := range Languages {
+		if lexers.Get(l.Name) == nil {
+			t.Errorf("no lexer for %q", l.Name)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Package markdown renders Markdown snippets as HTML. The output of goldmark
+// goes through a strict allow-list sanitizer, so only formatting elements and
+// plain links remain whatever the author wrote.
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Raw HTML is left out by goldmark unless it's told otherwise, the sanitizer
+// only has to deal with what the Markdown syntax itself produces.
+var converter = goldmark.New(
+	goldmark.WithExtensions(extension.Table, extension.Strikethrough, extension.Linkify),
+)
+
+var policy = newPolicy()
+
+// newPolicy allows the elements of headings, paragraphs, lists, quotes, code,
+// tables and links. Images aren't allowed, they would load from anywhere when
+// the snippet is viewed.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements("h1", "h2", "h3", "h4", "h5", "h6", "p", "br", "hr", "blockquote",
+		"ul", "ol", "li", "strong", "em", "del", "code", "pre",
+		"table", "thead", "tbody", "tr", "th", "td")
+
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	// The language of fenced code blocks
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[a-zA-Z0-9+#_-]+$`)).OnElements("code")
+
+	return p
+}
+
+// HTML converts Markdown to sanitized HTML
+func HTML(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := converter.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
@@ -0,0 +1,44 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		want     []string
+		dontWant []string
+	}{
+		{"Heading", "# Runbook", []string{"<h1>Runbook</h1>"}, nil},
+		{"List", "1. Stop\n2. Start", []string{"<ol>", "<li>Stop</li>"}, nil},
+		{"Link", "[docs](https://example.com)", []string{`<a href="https://example.com" rel="nofollow noopener" target="_blank">docs</a>`}, nil},
+		{"Code", "```go\nfunc main() {}\n```", []string{`<pre><code class="language-go">func main() {}`}, nil},
+		{"Raw HTML", "<script>alert(1)</script>\n\n<b onclick='x()'>bold</b>", nil, []string{"<script", "onclick", "<b"}},
+		{"Javascript link", "[click](javascript:alert(1))", []string{"click"}, []string{"javascript:", "href"}},
+		{"Image", "![logo](https://example.com/logo.png)", nil, []string{"<img", "logo.png"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HTML(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("want %q in %q", want, got)
+				}
+			}
+
+			for _, dontWant := range tt.dontWant {
+				if strings.Contains(string(got), dontWant) {
+					t.Errorf("don't want %q in %q", dontWant, got)
+				}
+			}
+		})
+	}
+}
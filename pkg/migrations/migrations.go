@@ -0,0 +1,340 @@
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The SQL files are embedded in the binary so every environment runs exactly
+// the same schema changes. There's one directory per driver because the
+// dialects differ (AUTO_INCREMENT vs SERIAL, DATETIME vs TIMESTAMPTZ...).
+// Files are named <version>_<name>.<up|down>.sql, e.g.
+// 0001_create_snippets_table.up.sql
+//
+//go:embed mysql/*.sql postgres/*.sql sqlite3/*.sql
+var files embed.FS
+
+// The table recording which migrations were applied, per driver.
+var schemaMigrationsTable = map[string]string{
+	"mysql": `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at DATETIME NOT NULL)`,
+	"postgres": `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL)`,
+	"sqlite3": `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at DATETIME NOT NULL)`,
+}
+
+// Migration is a single numbered schema change with the SQL to apply and to
+// revert it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied to the database.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the embedded migrations of a driver to a database.
+type Migrator struct {
+	DB         *sql.DB
+	driver     string
+	migrations []Migration
+}
+
+// New returns a Migrator for the migrations of the given driver (mysql,
+// postgres or sqlite3).
+func New(db *sql.DB, driver string) (*Migrator, error) {
+	if _, ok := schemaMigrationsTable[driver]; !ok {
+		return nil, fmt.Errorf("migrations: unsupported driver %q", driver)
+	}
+
+	migrations, err := load(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, driver: driver, migrations: migrations}, nil
+}
+
+// load reads and pairs the up and down files of a driver, sorted by version.
+func load(driver string) ([]Migration, error) {
+	names, err := fs.Glob(files, path.Join(driver, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, name := range names {
+		base := path.Base(name)
+
+		// Split "0001_create_snippets_table.up.sql" into its parts.
+		parts := strings.SplitN(strings.TrimSuffix(base, ".sql"), "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("migrations: invalid file name %s", name)
+		}
+
+		version, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("migrations: invalid version in %s", name)
+		}
+
+		body, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version}
+			byVersion[version] = m
+		}
+
+		switch {
+		case strings.HasSuffix(parts[1], ".up"):
+			m.Name = strings.TrimSuffix(parts[1], ".up")
+			m.Up = string(body)
+		case strings.HasSuffix(parts[1], ".down"):
+			m.Name = strings.TrimSuffix(parts[1], ".down")
+			m.Down = string(body)
+		default:
+			return nil, fmt.Errorf("migrations: %s is neither an up nor a down migration", name)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrations: version %d needs both an up and a down file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// placeholder returns the n-th (1 based) bind variable for the driver
+func (m *Migrator) placeholder(n int) string {
+	if m.driver == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+
+	return "?"
+}
+
+// applied returns the time each applied migration was applied at, by version.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	_, err := m.DB.Exec(schemaMigrationsTable[m.driver])
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// Up applies every pending migration in order and returns the ones that were
+// applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+
+		stmt := fmt.Sprintf(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (%s, %s, CURRENT_TIMESTAMP)`,
+			m.placeholder(1), m.placeholder(2))
+		err = m.exec(mig.Up, stmt, mig.Version, mig.Name)
+		if err != nil {
+			return done, fmt.Errorf("migrations: applying %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+
+		done = append(done, mig)
+	}
+
+	return done, nil
+}
+
+// Down reverts the most recently applied migration. It returns nil when there
+// was nothing to revert.
+func (m *Migrator) Down() (*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+
+		stmt := fmt.Sprintf(`DELETE FROM schema_migrations WHERE version = %s`, m.placeholder(1))
+		err = m.exec(mig.Down, stmt, mig.Version)
+		if err != nil {
+			return nil, fmt.Errorf("migrations: reverting %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+
+		return &mig, nil
+	}
+
+	return nil, nil
+}
+
+// Baseline records the migrations up to version as applied without running
+// them, for a database whose tables were created by hand before there were
+// migrations. The later migrations can then be applied with Up. It refuses a
+// database where migrations were applied already.
+func (m *Migrator) Baseline(version int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(applied) > 0 {
+		return nil, errors.New("migrations: the database already has applied migrations")
+	}
+
+	known := false
+	for _, mig := range m.migrations {
+		known = known || mig.Version == version
+	}
+
+	if !known {
+		return nil, fmt.Errorf("migrations: unknown version %d", version)
+	}
+
+	done := []Migration{}
+	for _, mig := range m.migrations {
+		if mig.Version > version {
+			break
+		}
+
+		stmt := fmt.Sprintf(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (%s, %s, CURRENT_TIMESTAMP)`,
+			m.placeholder(1), m.placeholder(2))
+		if err = m.exec("", stmt, mig.Version, mig.Name); err != nil {
+			return done, fmt.Errorf("migrations: recording %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+
+		done = append(done, mig)
+	}
+
+	return done, nil
+}
+
+// Status lists every known migration and whether it was applied.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []Status{}
+	for _, mig := range m.migrations {
+		appliedAt, ok := applied[mig.Version]
+		statuses = append(statuses, Status{Migration: mig, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return statuses, nil
+}
+
+// exec runs the statements of a migration file followed by the bookkeeping
+// statement in a single transaction. MySQL implicitly commits DDL statements
+// so there a failing migration can be left half applied, Postgres and SQLite
+// roll it back completely.
+func (m *Migrator) exec(script, stmt string, args ...interface{}) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, s := range split(script) {
+		if _, err = tx.Exec(s); err != nil {
+			return err
+		}
+	}
+
+	if _, err = tx.Exec(stmt, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// split breaks a script into its statements. Not every driver accepts several
+// statements in a single Exec (the MySQL one needs multiStatements=true in the
+// DSN) so they're run one by one. Statements must end with a semicolon at the
+// end of a line, and lines starting with -- are comments.
+func split(script string) []string {
+	statements := []string{}
+	current := []string{}
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.Join(current, "\n"))
+			current = current[:0]
+		}
+	}
+
+	if len(current) > 0 {
+		statements = append(statements, strings.Join(current, "\n"))
+	}
+
+	return statements
+}
@@ -0,0 +1,164 @@
+package migrations
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestLoad(t *testing.T) {
+	var want []Migration
+
+	for _, driver := range []string{"mysql", "postgres", "sqlite3"} {
+		migrations, err := load(driver)
+		if err != nil {
+			t.Fatalf("%s: %v", driver, err)
+		}
+
+		// Every driver must ship the same list of migrations
+		versions := []Migration{}
+		for _, m := range migrations {
+			versions = append(versions, Migration{Version: m.Version, Name: m.Name})
+		}
+
+		if want == nil {
+			want = versions
+		} else if !reflect.DeepEqual(versions, want) {
+			t.Errorf("%s: want migrations %v, got %v", driver, want, versions)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	script := `-- A comment
+CREATE TABLE a (
+    id INTEGER
+);
+
+CREATE INDEX idx_a ON a(id);
+`
+	want := []string{"CREATE TABLE a (\n    id INTEGER\n);", "CREATE INDEX idx_a ON a(id);"}
+
+	if got := split(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := New(db, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(done) != len(m.migrations) {
+		t.Errorf("want %d migrations applied, got %d", len(m.migrations), len(done))
+	}
+
+	// Running it again is a no-op
+	done, err = m.Up()
+	if err != nil || len(done) != 0 {
+		t.Errorf("want nothing applied, got %d (%v)", len(done), err)
+	}
+
+	last := m.migrations[len(m.migrations)-1]
+	reverted, err := m.Down()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reverted == nil || reverted.Version != last.Version {
+		t.Fatalf("want version %d reverted, got %v", last.Version, reverted)
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range statuses {
+		if want := s.Version != last.Version; s.Applied != want {
+			t.Errorf("version %d: want applied %t, got %t", s.Version, want, s.Applied)
+		}
+	}
+
+	// Revert everything, then check the tables are really gone
+	for reverted != nil {
+		if reverted, err = m.Down(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count int
+	err = db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name IN ('snippets', 'users')`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Errorf("want no tables left, got %d", count)
+	}
+}
+
+func TestMigratorBaseline(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := New(db, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.Baseline(99); err == nil {
+		t.Error("want an error for an unknown version")
+	}
+
+	// The tables of the first two migrations were created by hand
+	for _, mig := range m.migrations[:2] {
+		for _, stmt := range split(mig.Up) {
+			if _, err = db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	done, err := m.Baseline(2)
+	if err != nil || len(done) != 2 {
+		t.Fatalf("want 2 migrations recorded, got %d (%v)", len(done), err)
+	}
+
+	done, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(done) != len(m.migrations)-2 || done[0].Version != 3 {
+		t.Errorf("want the migrations after the baseline applied, got %d", len(done))
+	}
+
+	if _, err = m.Baseline(2); err == nil {
+		t.Error("want an error for a database with applied migrations")
+	}
+}
+
+func TestNewUnsupportedDriver(t *testing.T) {
+	if _, err := New(nil, "oracle"); err == nil {
+		t.Error("want an error for an unsupported driver")
+	}
+}
@@ -0,0 +1,16 @@
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    version INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    editor_id INTEGER NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_revisions_uc_version UNIQUE (snippet_id, version),
+    CONSTRAINT fk_snippet_revisions_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id),
+    CONSTRAINT fk_snippet_revisions_editor_id FOREIGN KEY (editor_id) REFERENCES users(id)
+);
+
+-- Existing snippets start their history with their current content.
+INSERT INTO snippet_revisions (snippet_id, version, title, content, editor_id, created)
+SELECT id, 1, title, content, user_id, created FROM snippets;
@@ -0,0 +1,4 @@
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(22) NULL;
+-- Existing snippets get a random slug of their own so their links keep working
+UPDATE snippets SET slug = SUBSTRING(SHA2(CONCAT(id, RAND()), 256), 1, 16);
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets(slug);
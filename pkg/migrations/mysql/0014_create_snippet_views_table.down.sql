@@ -0,0 +1,3 @@
+DROP TABLE snippet_views;
+ALTER TABLE snippets DROP COLUMN last_viewed;
+ALTER TABLE snippets DROP COLUMN views;
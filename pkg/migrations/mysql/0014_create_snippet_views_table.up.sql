@@ -0,0 +1,10 @@
+ALTER TABLE snippets ADD COLUMN views INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE snippets ADD COLUMN last_viewed DATETIME NULL;
+
+CREATE TABLE snippet_views (
+    snippet_id INTEGER NOT NULL,
+    day DATE NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, day),
+    CONSTRAINT fk_snippet_views_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id)
+);
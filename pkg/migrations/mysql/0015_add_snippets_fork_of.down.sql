@@ -0,0 +1,3 @@
+ALTER TABLE snippets DROP FOREIGN KEY fk_snippets_fork_of;
+
+ALTER TABLE snippets DROP COLUMN fork_of;
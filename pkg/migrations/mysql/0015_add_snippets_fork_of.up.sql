@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN fork_of INTEGER NULL;
+
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_fork_of FOREIGN KEY (fork_of) REFERENCES snippets(id);
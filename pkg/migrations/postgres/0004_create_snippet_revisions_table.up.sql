@@ -0,0 +1,14 @@
+CREATE TABLE snippet_revisions (
+    id SERIAL PRIMARY KEY,
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id),
+    version INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    editor_id INTEGER NULL REFERENCES users(id),
+    created TIMESTAMPTZ NOT NULL,
+    CONSTRAINT snippet_revisions_uc_version UNIQUE (snippet_id, version)
+);
+
+-- Existing snippets start their history with their current content.
+INSERT INTO snippet_revisions (snippet_id, version, title, content, editor_id, created)
+SELECT id, 1, title, content, user_id, created FROM snippets;
@@ -0,0 +1,2 @@
+UPDATE snippets SET expires = 'infinity' WHERE expires IS NULL;
+ALTER TABLE snippets ALTER COLUMN expires SET NOT NULL;
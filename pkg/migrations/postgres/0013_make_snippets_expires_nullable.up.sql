@@ -0,0 +1,2 @@
+-- Snippets that never expire have a NULL expiry date
+ALTER TABLE snippets ALTER COLUMN expires DROP NOT NULL;
@@ -0,0 +1,9 @@
+ALTER TABLE snippets ADD COLUMN views INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE snippets ADD COLUMN last_viewed TIMESTAMPTZ NULL;
+
+CREATE TABLE snippet_views (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id),
+    day DATE NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, day)
+);
@@ -0,0 +1,2 @@
+DROP INDEX idx_snippets_fork_of;
+ALTER TABLE snippets DROP COLUMN fork_of;
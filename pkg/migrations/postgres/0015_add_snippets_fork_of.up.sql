@@ -0,0 +1,2 @@
+ALTER TABLE snippets ADD COLUMN fork_of INTEGER NULL REFERENCES snippets(id);
+CREATE INDEX idx_snippets_fork_of ON snippets(fork_of);
@@ -0,0 +1,5 @@
+-- SQLite can't drop a column used by a foreign key, which the down migration
+-- needs, and doesn't enforce foreign keys by default anyway.
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
@@ -0,0 +1 @@
+-- Only MySQL has a full-text index, the other databases search with LIKE.
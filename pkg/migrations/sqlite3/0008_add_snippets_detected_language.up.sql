@@ -0,0 +1,2 @@
+ALTER TABLE snippets ADD COLUMN detected_language VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE snippets ADD COLUMN language_confidence REAL NOT NULL DEFAULT 0;
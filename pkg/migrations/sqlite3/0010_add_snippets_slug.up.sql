@@ -0,0 +1,4 @@
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(22) NULL;
+-- Existing snippets get a random slug of their own so their links keep working
+UPDATE snippets SET slug = lower(hex(randomblob(8)));
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets(slug);
@@ -0,0 +1,27 @@
+UPDATE snippets SET expires = '9999-12-31 23:59:59' WHERE expires IS NULL;
+
+CREATE TABLE snippets_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    user_id INTEGER NULL,
+    language VARCHAR(20) NOT NULL DEFAULT '',
+    detected_language VARCHAR(20) NOT NULL DEFAULT '',
+    language_confidence REAL NOT NULL DEFAULT 0,
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    slug VARCHAR(22) NULL,
+    burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    hashed_password CHAR(60) NULL
+);
+
+INSERT INTO snippets_new (id, title, content, created, expires, user_id, language, detected_language, language_confidence, visibility, slug, burn_after_reading, hashed_password)
+SELECT id, title, content, created, expires, user_id, language, detected_language, language_confidence, visibility, slug, burn_after_reading, hashed_password FROM snippets;
+
+DROP TABLE snippets;
+ALTER TABLE snippets_new RENAME TO snippets;
+
+CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets(slug);
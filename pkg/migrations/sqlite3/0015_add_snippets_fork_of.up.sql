@@ -0,0 +1,4 @@
+-- SQLite can't drop a column that's part of a foreign key, and doesn't
+-- enforce them anyway, so fork_of is a plain column like user_id.
+ALTER TABLE snippets ADD COLUMN fork_of INTEGER NULL;
+CREATE INDEX idx_snippets_fork_of ON snippets(fork_of);
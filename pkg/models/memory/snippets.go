@@ -0,0 +1,500 @@
+package memory
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SnippetModel keeps snippets in a map guarded by a RWMutex so it's safe for
+// the concurrent access the HTTP server makes to it. Nothing is persisted, the
+// data is lost when the process exits.
+type SnippetModel struct {
+	mu       sync.RWMutex
+	lastID   int
+	snippets map[int]*models.Snippet
+	// The ids of the snippets by slug
+	slugs map[string]int
+	// The hashed access passwords of the protected snippets by id
+	passwords map[int][]byte
+	// The history of each snippet, oldest version first
+	revisions map[int][]*models.Revision
+	// The number of views of each snippet by day
+	views map[int]map[time.Time]int
+	// Used to look up author names, like the SQL models join the users table.
+	users *UserModel
+}
+
+func NewSnippetModel(users *UserModel) *SnippetModel {
+	return &SnippetModel{
+		snippets:  map[int]*models.Snippet{},
+		slugs:     map[string]int{},
+		passwords: map[int][]byte{},
+		revisions: map[int][]*models.Revision{},
+		views:     map[int]map[time.Time]int{},
+		users:     users,
+	}
+}
+
+// Insert inserts a new snippet that expires at s.Expires
+func (m *SnippetModel) Insert(s *models.Snippet) (int, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+	s.Slug = slug
+
+	var hashedPassword []byte
+	if s.Password != "" {
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(s.Password), 12)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	m.lastID++
+	visibility := s.Visibility
+	if visibility == "" {
+		visibility = models.VisibilityPublic
+	}
+	format := s.Format
+	if format == "" {
+		format = models.FormatCode
+	}
+	m.snippets[m.lastID] = &models.Snippet{
+		ID:                 m.lastID,
+		Slug:               slug,
+		UserID:             s.UserID,
+		ForkOf:             s.ForkOf,
+		Title:              s.Title,
+		Content:            s.Content,
+		Language:           s.Language,
+		DetectedLanguage:   s.DetectedLanguage,
+		LanguageConfidence: s.LanguageConfidence,
+		Visibility:         visibility,
+		Format:             format,
+		BurnAfterReading:   s.BurnAfterReading,
+		Protected:          hashedPassword != nil,
+		Created:            now,
+		Expires:            s.Expires,
+		Tags:               sortedTags(s.Tags),
+	}
+	m.slugs[slug] = m.lastID
+	if hashedPassword != nil {
+		m.passwords[m.lastID] = hashedPassword
+	}
+	m.addRevision(m.snippets[m.lastID], s.UserID, now)
+
+	return m.lastID, nil
+}
+
+// sortedTags returns a sorted copy of the tags without duplicates, or nil when
+// there are none like the SQL models. The stored slices are never modified so
+// the copies of the snippets handed out can share them.
+func sortedTags(tags []string) []string {
+	var sorted []string
+	seen := map[string]bool{}
+	for _, t := range tags {
+		if !seen[t] {
+			seen[t] = true
+			sorted = append(sorted, t)
+		}
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// Tagged returns a page of the unexpired snippets with the given tag
+func (m *SnippetModel) Tagged(tag string, after, before *models.Cursor, limit int) (*models.SnippetPage, error) {
+	match := func(s *models.Snippet) bool {
+		for _, t := range s.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	}
+
+	return m.page(match, after, before, limit), nil
+}
+
+// addRevision records the current state of a snippet as its next version. The
+// caller must hold the write lock.
+func (m *SnippetModel) addRevision(s *models.Snippet, editorID int, now time.Time) {
+	m.revisions[s.ID] = append(m.revisions[s.ID], &models.Revision{
+		SnippetID: s.ID,
+		Version:   len(m.revisions[s.ID]) + 1,
+		Title:     s.Title,
+		Content:   s.Content,
+		EditorID:  editorID,
+		Created:   now,
+	})
+}
+
+// Get returns a specific snippet based on its id, as long as it hasn't expired
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	m.mu.RLock()
+	s, ok := m.snippets[id]
+	if !ok || s.IsExpired(time.Now()) {
+		m.mu.RUnlock()
+		return nil, models.ErrNoRecord
+	}
+
+	// Hand out a copy so callers can't modify the stored snippet without
+	// holding the lock.
+	c := *s
+	c.Version = len(m.revisions[id])
+	m.mu.RUnlock()
+
+	if c.UserID != 0 && m.users != nil {
+		if u, err := m.users.Get(c.UserID); err == nil {
+			c.AuthorName = u.Name
+		}
+	}
+
+	return &c, nil
+}
+
+// GetBySlug returns a specific snippet based on its slug, as long as it hasn't
+// expired
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	m.mu.RLock()
+	id, ok := m.slugs[slug]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return m.Get(id)
+}
+
+// Consume returns an unexpired snippet and expires it, both under the write
+// lock so that only one caller gets it
+func (m *SnippetModel) Consume(id int) (*models.Snippet, error) {
+	m.mu.Lock()
+	s, ok := m.snippets[id]
+	if !ok || s.IsExpired(time.Now()) {
+		m.mu.Unlock()
+		return nil, models.ErrNoRecord
+	}
+
+	s.Expires = time.Now().UTC()
+	c := *s
+	c.Version = len(m.revisions[id])
+	m.mu.Unlock()
+
+	if c.UserID != 0 && m.users != nil {
+		if u, err := m.users.Get(c.UserID); err == nil {
+			c.AuthorName = u.Name
+		}
+	}
+
+	return &c, nil
+}
+
+// CheckPassword returns ErrInvalidCredentials unless the password is the
+// access password of the snippet
+func (m *SnippetModel) CheckPassword(id int, password string) error {
+	m.mu.RLock()
+	hashedPassword, ok := m.passwords[id]
+	m.mu.RUnlock()
+
+	if !ok {
+		return models.ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Latest returns the 10 most recently created public snippets that haven't
+// expired
+func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	snippets := []*models.Snippet{}
+	for _, s := range m.snippets {
+		if !s.IsExpired(now) && s.Visibility == models.VisibilityPublic {
+			c := *s
+			snippets = append(snippets, &c)
+		}
+	}
+
+	sort.Slice(snippets, func(i, j int) bool {
+		if snippets[i].Created.Equal(snippets[j].Created) {
+			return snippets[i].ID > snippets[j].ID
+		}
+		return snippets[i].Created.After(snippets[j].Created)
+	})
+
+	if len(snippets) > 10 {
+		snippets = snippets[:10]
+	}
+
+	return snippets, nil
+}
+
+// Forks returns the unexpired snippets forked from a snippet, the newest first
+func (m *SnippetModel) Forks(id int) ([]*models.Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	forks := []*models.Snippet{}
+	for _, s := range m.snippets {
+		if !s.IsExpired(now) && s.ForkOf == id {
+			c := *s
+			forks = append(forks, &c)
+		}
+	}
+
+	sort.Slice(forks, func(i, j int) bool {
+		if forks[i].Created.Equal(forks[j].Created) {
+			return forks[i].ID > forks[j].ID
+		}
+		return forks[i].Created.After(forks[j].Created)
+	})
+
+	return forks, nil
+}
+
+// Update changes the title, content, detected language and expiry of a
+// snippet and records the new version in its history
+func (m *SnippetModel) Update(s *models.Snippet, editorID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.snippets[s.ID]
+	now := time.Now().UTC()
+	if !ok || stored.IsExpired(now) {
+		return models.ErrNoRecord
+	}
+
+	stored.Title = s.Title
+	stored.Content = s.Content
+	stored.DetectedLanguage = s.DetectedLanguage
+	stored.LanguageConfidence = s.LanguageConfidence
+	stored.Expires = s.Expires
+	m.addRevision(stored, editorID, now)
+
+	return nil
+}
+
+// Extend changes the expiry of an unexpired snippet, without recording a new
+// version in its history
+func (m *SnippetModel) Extend(id int, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if !ok || s.IsExpired(time.Now()) {
+		return models.ErrNoRecord
+	}
+
+	s.Expires = expires
+
+	return nil
+}
+
+// Delete removes a snippet and its history before it expires. Its forks are
+// kept.
+func (m *SnippetModel) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	m.remove(s)
+
+	return nil
+}
+
+// remove deletes a snippet with everything attached to it, its forks are kept
+// without a parent. The caller must hold the write lock.
+func (m *SnippetModel) remove(s *models.Snippet) {
+	delete(m.slugs, s.Slug)
+	delete(m.passwords, s.ID)
+	delete(m.snippets, s.ID)
+	delete(m.revisions, s.ID)
+	delete(m.views, s.ID)
+
+	for _, fork := range m.snippets {
+		if fork.ForkOf == s.ID {
+			fork.ForkOf = 0
+		}
+	}
+}
+
+// Revisions returns every version of a snippet, the newest first
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
+	m.mu.RLock()
+	stored := m.revisions[id]
+	revisions := make([]*models.Revision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		c := *stored[i]
+		revisions = append(revisions, &c)
+	}
+	m.mu.RUnlock()
+
+	for _, r := range revisions {
+		m.setEditorName(r)
+	}
+
+	return revisions, nil
+}
+
+// Revision returns a single version of a snippet
+func (m *SnippetModel) Revision(id, version int) (*models.Revision, error) {
+	m.mu.RLock()
+	stored := m.revisions[id]
+	if version < 1 || version > len(stored) {
+		m.mu.RUnlock()
+		return nil, models.ErrNoRecord
+	}
+	r := *stored[version-1]
+	m.mu.RUnlock()
+
+	m.setEditorName(&r)
+
+	return &r, nil
+}
+
+func (m *SnippetModel) setEditorName(r *models.Revision) {
+	if r.EditorID == 0 || m.users == nil {
+		return
+	}
+
+	if u, err := m.users.Get(r.EditorID); err == nil {
+		r.EditorName = u.Name
+	}
+}
+
+// DeleteExpired removes a batch of expired snippets along with their history
+func (m *SnippetModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expired := []*models.Snippet{}
+	for _, s := range m.snippets {
+		if s.IsExpired(before) {
+			expired = append(expired, s)
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].Expires.Before(expired[j].Expires)
+	})
+
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	for _, s := range expired {
+		m.remove(s)
+	}
+
+	return len(expired), nil
+}
+
+// Page returns a page of unexpired snippets around a cursor
+func (m *SnippetModel) Page(after, before *models.Cursor, limit int) (*models.SnippetPage, error) {
+	return m.page(nil, after, before, limit), nil
+}
+
+// Search returns a page of the unexpired snippets with every term of the query
+// in their title or content, ignoring case.
+func (m *SnippetModel) Search(query string, after, before *models.Cursor, limit int) (*models.SnippetPage, error) {
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return models.NewSnippetPage(nil, after, before, limit), nil
+	}
+
+	// Only the title of protected snippets is searched, the matches would give
+	// their content away otherwise.
+	match := func(s *models.Snippet) bool {
+		title, content := strings.ToLower(s.Title), strings.ToLower(s.Content)
+		if s.Protected {
+			content = ""
+		}
+		for _, t := range terms {
+			if !strings.Contains(title, t) && !strings.Contains(content, t) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return m.page(match, after, before, limit), nil
+}
+
+// page returns a page of the unexpired public snippets for which match returns
+// true, or of all of them when match is nil.
+func (m *SnippetModel) page(match func(*models.Snippet) bool, after, before *models.Cursor, limit int) *models.SnippetPage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	snippets := []*models.Snippet{}
+	for _, s := range m.snippets {
+		if s.IsExpired(now) || s.Visibility != models.VisibilityPublic || (match != nil && !match(s)) {
+			continue
+		}
+
+		switch {
+		case after != nil && !cursorLess(models.CursorFor(s), after):
+			continue
+		case before != nil && !cursorLess(before, models.CursorFor(s)):
+			continue
+		}
+
+		c := *s
+		snippets = append(snippets, &c)
+	}
+
+	// Newest first, or oldest first when paging backwards like the SQL models
+	sort.Slice(snippets, func(i, j int) bool {
+		a, b := models.CursorFor(snippets[i]), models.CursorFor(snippets[j])
+		if before != nil {
+			return cursorLess(a, b)
+		}
+		return cursorLess(b, a)
+	})
+
+	if len(snippets) > limit+1 {
+		snippets = snippets[:limit+1]
+	}
+
+	return models.NewSnippetPage(snippets, after, before, limit)
+}
+
+// cursorLess reports whether a comes before b in creation order
+func cursorLess(a, b *models.Cursor) bool {
+	if a.Created.Equal(b.Created) {
+		return a.ID < b.ID
+	}
+	return a.Created.Before(b.Created)
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+)
+
+func TestSnippetModelGet(t *testing.T) {
+	users := NewUserModel()
+	if err := users.Insert("Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	m := NewSnippetModel(users)
+
+	id, err := m.Insert(&models.Snippet{UserID: 1, Title: "An old silent pond", Content: "An old silent pond..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Title != "An old silent pond" {
+		t.Errorf("want %q, got %q", "An old silent pond", s.Title)
+	}
+
+	if s.AuthorName != "Alice" || !s.IsOwner(1) || s.IsOwner(2) {
+		t.Errorf("want snippet owned by Alice, got user %d %q", s.UserID, s.AuthorName)
+	}
+
+	// Expire the snippet behind the model's back
+	m.snippets[id].Expires = time.Now().Add(-time.Minute)
+
+	if _, err := m.Get(id); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v, got %v", models.ErrNoRecord, err)
+	}
+
+	if _, err := m.Get(id + 1); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v, got %v", models.ErrNoRecord, err)
+	}
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	m := NewSnippetModel(nil)
+
+	for i := 0; i < 12; i++ {
+		if _, err := m.Insert(&models.Snippet{Title: "Title", Content: "Content"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.snippets[12].Expires = time.Now().Add(-time.Minute)
+
+	snippets, err := m.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snippets) != 10 {
+		t.Fatalf("want %d snippets, got %d", 10, len(snippets))
+	}
+
+	if snippets[0].ID != 11 || snippets[9].ID != 2 {
+		t.Errorf("want IDs 11 to 2, got %d to %d", snippets[0].ID, snippets[9].ID)
+	}
+}
+
+func TestSnippetModelDeleteExpired(t *testing.T) {
+	m := NewSnippetModel(nil)
+
+	for i := 0; i < 5; i++ {
+		if _, err := m.Insert(&models.Snippet{Title: "Title", Content: "Content"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for id := 1; id <= 3; id++ {
+		m.snippets[id].Expires = time.Now().Add(-time.Duration(id) * time.Minute)
+	}
+
+	n, err := m.DeleteExpired(time.Now(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The two that expired first go first
+	if _, ok := m.snippets[1]; n != 2 || !ok {
+		t.Errorf("want snippets 2 and 3 deleted, got %d deleted", n)
+	}
+
+	if n, _ = m.DeleteExpired(time.Now(), 2); n != 1 || len(m.snippets) != 2 || len(m.revisions) != 2 {
+		t.Errorf("want the last expired snippet deleted, got %d deleted and %d left", n, len(m.snippets))
+	}
+}
@@ -0,0 +1,28 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) *storetest.Backend {
+		users := NewUserModel()
+		snippets := NewSnippetModel(users)
+		return &storetest.Backend{
+			Snippets: snippets,
+			Users:    users,
+			SetCreated: func(created time.Time) error {
+				snippets.mu.Lock()
+				defer snippets.mu.Unlock()
+
+				for _, s := range snippets.snippets {
+					s.Created = created
+				}
+				return nil
+			},
+		}
+	})
+}
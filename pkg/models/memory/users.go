@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserModel keeps users in memory, indexed both by ID and by email so that
+// the unique email constraint of the users table can be enforced.
+type UserModel struct {
+	mu      sync.RWMutex
+	lastID  int
+	users   map[int]*models.User
+	byEmail map[string]int
+}
+
+func NewUserModel() *UserModel {
+	return &UserModel{
+		users:   map[int]*models.User{},
+		byEmail: map[string]int{},
+	}
+}
+
+func (m *UserModel) Insert(name, email, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The users table uses a case insensitive collation for the email column,
+	// so mimic it here.
+	key := strings.ToLower(email)
+	if _, ok := m.byEmail[key]; ok {
+		return models.ErrDuplicateEmail
+	}
+
+	m.lastID++
+	m.users[m.lastID] = &models.User{
+		ID:             m.lastID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC(),
+		Active:         true,
+	}
+	m.byEmail[key] = m.lastID
+
+	return nil
+}
+
+func (m *UserModel) Get(id int) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	// Like the SQL model, don't hand out the password hash.
+	return &models.User{
+		ID:      u.ID,
+		Name:    u.Name,
+		Email:   u.Email,
+		Created: u.Created,
+		Active:  u.Active,
+	}, nil
+}
+
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	m.mu.RLock()
+	id, ok := m.byEmail[strings.ToLower(email)]
+	var u models.User
+	if ok {
+		u = *m.users[id]
+	}
+	m.mu.RUnlock()
+
+	if !ok || !u.Active {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(u.HashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		}
+
+		return 0, err
+	}
+
+	return id, nil
+}
@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+)
+
+func TestUserModel(t *testing.T) {
+	m := NewUserModel()
+
+	if err := m.Insert("Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.Insert("Alice", "Alice@Example.com", "validPa$$word")
+	if !errors.Is(err, models.ErrDuplicateEmail) {
+		t.Errorf("want %v, got %v", models.ErrDuplicateEmail, err)
+	}
+
+	id, err := m.Authenticate("alice@example.com", "validPa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !u.Active || u.Email != "alice@example.com" {
+		t.Errorf("unexpected user %+v", u)
+	}
+
+	if _, err := m.Authenticate("alice@example.com", "wrongPa$$word"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want %v, got %v", models.ErrInvalidCredentials, err)
+	}
+
+	m.users[id].Active = false
+	if _, err := m.Authenticate("alice@example.com", "validPa$$word"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want %v for an inactive user, got %v", models.ErrInvalidCredentials, err)
+	}
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+)
+
+// AddViews adds the counted views to their snippets
+func (m *SnippetModel) AddViews(counts []*models.ViewCount) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range counts {
+		s, ok := m.snippets[c.SnippetID]
+		if !ok {
+			continue
+		}
+
+		s.Views += c.Views
+		if c.LastViewed.After(s.LastViewed) {
+			s.LastViewed = c.LastViewed
+		}
+
+		if m.views[s.ID] == nil {
+			m.views[s.ID] = map[time.Time]int{}
+		}
+		m.views[s.ID][day(c.Day)] += c.Views
+	}
+
+	return nil
+}
+
+// DailyViews returns the views of a snippet per day since the given one
+func (m *SnippetModel) DailyViews(id int, since time.Time) ([]*models.DailyViews, error) {
+	since = day(since)
+	days := []*models.DailyViews{}
+
+	m.mu.RLock()
+	for d, views := range m.views[id] {
+		if !d.Before(since) {
+			days = append(days, &models.DailyViews{Day: d, Views: views})
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Day.Before(days[j].Day)
+	})
+
+	return days, nil
+}
+
+// day returns midnight UTC of the day of t
+func day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
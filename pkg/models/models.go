@@ -1,8 +1,14 @@
 package models
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
 var (
@@ -11,15 +17,113 @@ var (
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	ErrInvalidCursor = errors.New("models: invalid cursor")
+)
+
+// Who can see a snippet. Public snippets are listed everywhere, unlisted ones
+// can only be reached by their link and private ones only by their author.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// How the content of a snippet is shown. Code is highlighted and Markdown is
+// rendered to HTML.
+const (
+	FormatCode     = "code"
+	FormatMarkdown = "markdown"
 )
 
-// Snippet represents the snippet object
+// Snippet represents the snippet object. UserID is 0 for snippets created
+// before authors were recorded.
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID                 int
+	Slug               string // random, for the links to unlisted snippets
+	UserID             int
+	ForkOf             int // the id of the snippet this one was forked from, 0 for none
+	AuthorName         string
+	Title              string
+	Content            string
+	Language           string  // a highlight language name, empty for plain text
+	DetectedLanguage   string  // guessed when the author didn't pick one
+	LanguageConfidence float64 // between 0 and 1
+	Visibility         string
+	Format             string
+	BurnAfterReading   bool   // expires on the first view by anyone but the author
+	Password           string // only read by Insert, which stores its bcrypt hash
+	Protected          bool   // has an access password
+	Views              int
+	LastViewed         time.Time // the zero time when it was never viewed
+	Created            time.Time
+	Expires            time.Time // the zero time for snippets that never expire
+	Tags               []string  // sorted by name
+	Version            int       // of the latest revision, only set for a single snippet
+}
+
+// IsOwner reports whether the user with the given id created the snippet and
+// may therefore modify it. Snippets without an author can't be modified.
+func (s *Snippet) IsOwner(userID int) bool {
+	return s.UserID != 0 && s.UserID == userID
+}
+
+// IsExpired reports whether the snippet had expired at the given time
+func (s *Snippet) IsExpired(t time.Time) bool {
+	return !s.Expires.IsZero() && !s.Expires.After(t)
+}
+
+// IsVisibleTo reports whether the user with the given id, 0 when anonymous,
+// may see the snippet.
+func (s *Snippet) IsVisibleTo(userID int) bool {
+	return s.Visibility != VisibilityPrivate || s.IsOwner(userID)
+}
+
+// Path returns the URL path of the snippet page. Only public snippets can be
+// reached by their id, which is easy to guess, the others need the slug. An
+// empty visibility is public, like in Insert.
+func (s *Snippet) Path() string {
+	if s.Visibility == VisibilityPublic || s.Visibility == "" || s.Slug == "" {
+		return fmt.Sprintf("/snippet/%d", s.ID)
+	}
+
+	return "/s/" + s.Slug
+}
+
+// The number of random bytes in a slug, encoded as 16 characters
+const slugBytes = 12
+
+// NewSlug returns a random URL-safe string to identify a snippet with
+func NewSlug() (string, error) {
+	b := make([]byte, slugBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HighlightLanguage returns the language the snippet is highlighted in: the
+// one picked by the author, or else the detected one.
+func (s *Snippet) HighlightLanguage() string {
+	if s.Language != "" {
+		return s.Language
+	}
+
+	return s.DetectedLanguage
+}
+
+// Revision is a version of a snippet. Every snippet has at least one revision,
+// the first being the snippet as it was created. EditorID is the user who
+// wrote that version.
+type Revision struct {
+	SnippetID  int
+	Version    int
+	Title      string
+	Content    string
+	EditorID   int
+	EditorName string
+	Created    time.Time
 }
 
 type User struct {
@@ -30,3 +134,204 @@ type User struct {
 	Created        time.Time
 	Active         bool
 }
+
+// SnippetStore is implemented by every storage backend able to persist
+// snippets. The handlers only depend on this interface so the backend can be
+// swapped, for example with the in-memory store in tests.
+type SnippetStore interface {
+	// Insert saves a new snippet with the UserID, ForkOf, Title, Content,
+	// Language, DetectedLanguage, LanguageConfidence, Visibility, Format,
+	// BurnAfterReading, Password, Tags and Expires of s. An empty Visibility is
+	// public, an empty Format is code and a zero Expires never expires. It
+	// returns the id of the new snippet and sets the generated Slug of s.
+	Insert(s *Snippet) (int, error)
+	// Get and GetBySlug return a snippet whatever its visibility, checking
+	// who may see it is up to the caller.
+	Get(id int) (*Snippet, error)
+	GetBySlug(slug string) (*Snippet, error)
+	// Consume returns an unexpired snippet and expires it at once, for burn
+	// after reading snippets. Only one caller ever gets the snippet, the
+	// others get ErrNoRecord.
+	Consume(id int) (*Snippet, error)
+	// CheckPassword returns ErrInvalidCredentials unless the password is the
+	// access password of the snippet.
+	CheckPassword(id int, password string) error
+	// Forks returns the unexpired snippets forked from a snippet, the newest
+	// first, whatever their visibility.
+	Forks(id int) ([]*Snippet, error)
+	// Latest and the paginated listings below only return public snippets.
+	Latest() ([]*Snippet, error)
+	// Page returns up to limit unexpired snippets, newest first. Pass after to
+	// get the page following a cursor, before for the page preceding it, or
+	// neither for the first page.
+	Page(after, before *Cursor, limit int) (*SnippetPage, error)
+	// Search returns a page of the unexpired snippets containing every term of
+	// the query in their title or content, newest first. Only the title of
+	// protected snippets is searched, their content is secret.
+	Search(query string, after, before *Cursor, limit int) (*SnippetPage, error)
+	// Tagged returns a page of the unexpired snippets with the given tag
+	Tagged(tag string, after, before *Cursor, limit int) (*SnippetPage, error)
+	// Update replaces the Title, Content, DetectedLanguage,
+	// LanguageConfidence and Expires of the snippet with the ID of s and
+	// records the new version as a revision by editorID.
+	Update(s *Snippet, editorID int) error
+	// Extend changes the expiry of a snippet, the zero time for never,
+	// without recording a new version.
+	Extend(id int, expires time.Time) error
+	// Delete removes a snippet, returning ErrNoRecord if there's none with
+	// that id.
+	Delete(id int) error
+	// DeleteExpired removes at most limit snippets (and their history) that
+	// expired at or before the given time. It returns how many were removed.
+	DeleteExpired(before time.Time, limit int) (int, error)
+	// Revisions returns the history of a snippet, newest version first.
+	Revisions(id int) ([]*Revision, error)
+	// Revision returns a single version of a snippet or ErrNoRecord.
+	Revision(id, version int) (*Revision, error)
+	// AddViews adds the counted views to the total and the daily views of
+	// their snippets. The views of snippets that were deleted are dropped.
+	AddViews(counts []*ViewCount) error
+	// DailyViews returns the days since the given one on which a snippet was
+	// viewed, oldest first.
+	DailyViews(id int, since time.Time) ([]*DailyViews, error)
+}
+
+// ViewCount is a number of views of a snippet on a day, counted in memory
+// before they're added to the snippet with AddViews
+type ViewCount struct {
+	SnippetID  int
+	Day        time.Time // midnight UTC
+	Views      int
+	LastViewed time.Time
+}
+
+// DailyViews is the number of views of a snippet on a day
+type DailyViews struct {
+	Day   time.Time
+	Views int
+}
+
+// UserStore is implemented by every storage backend able to persist users.
+type UserStore interface {
+	Insert(name, email, password string) error
+	Get(id int) (*User, error)
+	Authenticate(email, password string) (int, error)
+}
+
+// Cursor identifies a position in the listing of snippets, which is ordered
+// by creation date and then by id, newest first. The id breaks the ties
+// between snippets created in the same second.
+type Cursor struct {
+	Created time.Time
+	ID      int
+}
+
+// CursorFor returns the position of a snippet in the listing
+func CursorFor(s *Snippet) *Cursor {
+	return &Cursor{Created: s.Created, ID: s.ID}
+}
+
+// String encodes the cursor for use in URLs
+func (c *Cursor) String() string {
+	return fmt.Sprintf("%d_%d", c.Created.UnixNano(), c.ID)
+}
+
+// ParseCursor decodes a cursor encoded with Cursor.String
+func ParseCursor(s string) (*Cursor, error) {
+	parts := strings.SplitN(s, "_", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil || id < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{Created: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+// SnippetPage is a page of the snippets listing. Prev and Next point at the
+// first and last snippets of the page when there are more snippets before or
+// after it, and are nil otherwise.
+type SnippetPage struct {
+	Snippets []*Snippet
+	Prev     *Cursor
+	Next     *Cursor
+}
+
+// NewSnippetPage builds a page from the result of a query fetching limit+1
+// snippets, the extra one telling whether there's more to come. The query
+// runs newest first, except when paging backwards from before, in which case
+// it runs oldest first.
+func NewSnippetPage(snippets []*Snippet, after, before *Cursor, limit int) *SnippetPage {
+	more := len(snippets) > limit
+	if more {
+		snippets = snippets[:limit]
+	}
+
+	if before != nil {
+		for l, r := 0, len(snippets)-1; l < r; l, r = l+1, r-1 {
+			snippets[l], snippets[r] = snippets[r], snippets[l]
+		}
+	}
+
+	page := &SnippetPage{Snippets: snippets}
+	if len(snippets) == 0 {
+		return page
+	}
+
+	first, last := CursorFor(snippets[0]), CursorFor(snippets[len(snippets)-1])
+	switch {
+	case before != nil:
+		// We came back from the page after this one
+		page.Next = last
+		if more {
+			page.Prev = first
+		}
+	case after != nil:
+		// We came from the page before this one
+		page.Prev = first
+		if more {
+			page.Next = last
+		}
+	default:
+		if more {
+			page.Next = last
+		}
+	}
+
+	return page
+}
+
+// The maximum number of terms of a search query, the rest are ignored
+const maxSearchTerms = 10
+
+// SearchTerms splits a search query into the lowercased words to look for.
+// Words are made of letters and digits only, everything else separates them.
+func SearchTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, w := range words {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+
+		terms = append(terms, w)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+
+	return terms
+}
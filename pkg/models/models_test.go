@@ -0,0 +1,46 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCursor(t *testing.T) {
+	c := &Cursor{Created: time.Date(2020, 12, 17, 10, 0, 0, 123, time.UTC), ID: 42}
+
+	got, err := ParseCursor(c.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Created.Equal(c.Created) || got.ID != c.ID {
+		t.Errorf("want %v, got %v", c, got)
+	}
+
+	for _, s := range []string{"", "foo", "123", "123_foo", "foo_1", "123_0"} {
+		if _, err := ParseCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%q: want %v, got %v", s, ErrInvalidCursor, err)
+		}
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{}},
+		{"  Old   POND ", []string{"old", "pond"}},
+		{"+frog* -(jumps) \"in\" 100%", []string{"frog", "jumps", "in", "100"}},
+		{"pond Pond", []string{"pond"}},
+		{"a b c d e f g h i j k l", []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}},
+	}
+
+	for _, tt := range tests {
+		if got := SearchTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: want %q, got %q", tt.query, tt.want, got)
+		}
+	}
+}
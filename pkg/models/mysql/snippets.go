@@ -3,17 +3,25 @@ package mysql
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/eiliz/snippetbox/pkg/models"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// The columns scanned by query
+const snippetColumns = `id, COALESCE(slug, ''), COALESCE(user_id, 0), COALESCE(fork_of, 0), title, content, language, detected_language, language_confidence,
+					visibility, format, burn_after_reading, hashed_password IS NOT NULL, views, last_viewed, created, expires`
+
 // SnippetModel is a type that wraps a sql.DB connection pool
 type SnippetModel struct {
 	DB *sql.DB
 }
 
 // Insert inserts a new snippet into the db
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
+func (m *SnippetModel) Insert(s *models.Snippet) (int, error) {
 	// Use backticks to spread statement in multiple lines
 	// DB.Exec does 3 steps: creates a prepared statement which the database
 	// parses, compiles and stores for execution; passes the parameter values to
@@ -21,9 +29,36 @@ func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
 	// passed after the statement has been compiled they are treated as just data,
 	// they cannot result into an SQL injection; finally the prepared statement is
 	// closed/deallocated.
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-					VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	stmt := `INSERT INTO snippets (slug, user_id, title, content, language, detected_language, language_confidence, visibility, burn_after_reading, hashed_password, fork_of, format, created, expires)
+					VALUES(?, NULLIF(?, 0), ?, ?, ?, ?, ?, COALESCE(NULLIF(?, ''), 'public'), ?, NULLIF(?, ''), NULLIF(?, 0), COALESCE(NULLIF(?, ''), 'code'), UTC_TIMESTAMP(), ?)`
+	// Slugs are random so the links to unlisted snippets can't be guessed
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+	s.Slug = slug
+
+	// The access password is hashed like the passwords of the users, no
+	// password is stored as NULL.
+	var hashedPassword string
+	if s.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(s.Password), 12)
+		if err != nil {
+			return 0, err
+		}
+		hashedPassword = string(hash)
+	}
+
+	// The snippet, its tags and its first revision are saved together or not
+	// at all.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.Exec(stmt, s.Slug, s.UserID, s.Title, s.Content, s.Language, s.DetectedLanguage, s.LanguageConfidence, s.Visibility, s.BurnAfterReading, hashedPassword, s.ForkOf, s.Format, nullTime(s.Expires))
 	if err != nil {
 		return 0, err
 	}
@@ -32,40 +67,161 @@ func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
 	// inserted record in the snippets table
 	id, err := result.LastInsertId()
 	if err != nil {
-		return 0, nil
+		return 0, err
+	}
+
+	if err = insertTags(tx, int(id), s.Tags); err != nil {
+		return 0, err
+	}
+
+	if err = insertRevision(tx, int(id), s.UserID); err != nil {
+		return 0, err
 	}
 
 	// The ID returned is of type int64, we need to convert to int before returning
-	return int(id), nil
+	return int(id), tx.Commit()
 }
 
 // Get returns a specific snippet based on its id
 func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-					WHERE expires > UTC_TIMESTAMP() AND id = ?`
-	row := m.DB.QueryRow(stmt, id)
+	return m.get("s.id = ?", id)
+}
 
-	s := &models.Snippet{}
+// GetBySlug returns a specific snippet based on its slug
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	return m.get("s.slug = ?", slug)
+}
 
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+// selectSnippet selects the columns scanned by scanSnippet, which include the
+// name of the author and the current version
+const selectSnippet = `SELECT s.id, COALESCE(s.slug, ''), COALESCE(s.user_id, 0), COALESCE(s.fork_of, 0), COALESCE(u.name, ''), s.title, s.content, s.language,
+					s.detected_language, s.language_confidence, s.visibility, s.format, s.burn_after_reading, s.hashed_password IS NOT NULL, s.views, s.last_viewed, s.created, s.expires,
+					(SELECT COALESCE(MAX(r.version), 0) FROM snippet_revisions r WHERE r.snippet_id = s.id)
+					FROM snippets s LEFT JOIN users u ON u.id = s.user_id`
 
+// get returns the unexpired snippet matching a condition on its id or slug,
+// along with the name of its author and its tags
+func (m *SnippetModel) get(cond string, arg interface{}) (*models.Snippet, error) {
+	s, err := scanSnippet(m.DB.QueryRow(selectSnippet+` WHERE (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND `+cond, arg))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = m.loadTags([]*models.Snippet{s}); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// scanSnippet scans a row selected by selectSnippet
+func scanSnippet(row *sql.Row) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	var lastViewed, expires sql.NullTime
+
+	err := row.Scan(&s.ID, &s.Slug, &s.UserID, &s.ForkOf, &s.AuthorName, &s.Title, &s.Content, &s.Language, &s.DetectedLanguage, &s.LanguageConfidence, &s.Visibility, &s.Format, &s.BurnAfterReading, &s.Protected, &s.Views, &lastViewed, &s.Created, &expires, &s.Version)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, models.ErrNoRecord
 		}
 		return nil, err
 	}
+	s.LastViewed, s.Expires = lastViewed.Time, expires.Time
 
 	return s, nil
 }
 
-// Latest returns the 10 most recently created snippets
+// Consume returns an unexpired snippet and expires it in the same
+// transaction, so that a burn after reading snippet is only read once. It
+// returns ErrNoRecord when another request consumed the snippet first.
+func (m *SnippetModel) Consume(id int) (*models.Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	// Expiring the snippet before reading it locks its row, so a concurrent
+	// Consume waits for this one and then finds nothing left to update.
+	result, err := tx.Exec(`UPDATE snippets SET expires = UTC_TIMESTAMP() WHERE id = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP())`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		return nil, models.ErrNoRecord
+	}
+
+	s, err := scanSnippet(tx.QueryRow(selectSnippet+` WHERE s.id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if err = m.loadTags([]*models.Snippet{s}); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// CheckPassword returns ErrInvalidCredentials unless the password is the
+// access password of the snippet
+func (m *SnippetModel) CheckPassword(id int, password string) error {
+	var hashedPassword []byte
+	stmt := `SELECT hashed_password FROM snippets WHERE id = ? AND hashed_password IS NOT NULL`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrInvalidCredentials
+		}
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Latest returns the 10 most recently created public snippets
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-					WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+					WHERE (expires IS NULL OR expires > UTC_TIMESTAMP()) AND visibility = 'public' ORDER BY created DESC, id DESC LIMIT 10`
+
+	return m.query(stmt)
+}
+
+// Forks returns the unexpired snippets forked from a snippet, the newest first
+func (m *SnippetModel) Forks(id int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+					WHERE (expires IS NULL OR expires > UTC_TIMESTAMP()) AND fork_of = ? ORDER BY created DESC, id DESC`
+
+	return m.query(stmt, id)
+}
+
+// query runs a statement selecting the snippetColumns of snippets and returns
+// the rows with their tags
+func (m *SnippetModel) query(stmt string, args ...interface{}) ([]*models.Snippet, error) {
 	snippets := []*models.Snippet{}
 
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -74,11 +230,13 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 
 	for rows.Next() {
 		s := &models.Snippet{}
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		var lastViewed, expires sql.NullTime
+		err = rows.Scan(&s.ID, &s.Slug, &s.UserID, &s.ForkOf, &s.Title, &s.Content, &s.Language, &s.DetectedLanguage, &s.LanguageConfidence, &s.Visibility, &s.Format, &s.BurnAfterReading, &s.Protected, &s.Views, &lastViewed, &s.Created, &expires)
 
 		if err != nil {
 			return nil, err
 		}
+		s.LastViewed, s.Expires = lastViewed.Time, expires.Time
 		snippets = append(snippets, s)
 	}
 
@@ -86,5 +244,265 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 		return nil, err
 	}
 
+	if err = m.loadTags(snippets); err != nil {
+		return nil, err
+	}
+
 	return snippets, nil
 }
+
+// Update changes the title, content, detected language and expiry of a
+// snippet and records the new version in its history
+func (m *SnippetModel) Update(s *models.Snippet, editorID int) error {
+	stmt := `UPDATE snippets SET title = ?, content = ?, detected_language = ?, language_confidence = ?, expires = ?
+					WHERE id = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP())`
+	args := []interface{}{s.Title, s.Content, s.DetectedLanguage, s.LanguageConfidence, nullTime(s.Expires), s.ID}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// MySQL only counts the rows that actually changed so saving a snippet
+	// without modifications also affects 0 rows. Check if it exists at all.
+	if n == 0 {
+		var exists bool
+		stmt = `SELECT EXISTS(SELECT true FROM snippets WHERE id = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP()))`
+		if err = tx.QueryRow(stmt, s.ID).Scan(&exists); err != nil {
+			return err
+		}
+
+		if !exists {
+			return models.ErrNoRecord
+		}
+	}
+
+	if err = insertRevision(tx, s.ID, editorID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Extend changes the expiry of an unexpired snippet, without recording a new
+// version in its history
+func (m *SnippetModel) Extend(id int, expires time.Time) error {
+	stmt := `UPDATE snippets SET expires = ? WHERE id = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, nullTime(expires), id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// Like in Update, a snippet that already had this expiry affects 0 rows
+	if n == 0 {
+		var exists bool
+		stmt = `SELECT EXISTS(SELECT true FROM snippets WHERE id = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP()))`
+		if err = m.DB.QueryRow(stmt, id).Scan(&exists); err != nil {
+			return err
+		}
+
+		if !exists {
+			return models.ErrNoRecord
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a snippet, its tags, views and history before it expires
+func (m *SnippetModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_views WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	// The forks are kept, they only lose their parent
+	_, err = tx.Exec(`UPDATE snippets SET fork_of = NULL WHERE fork_of = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM snippets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return tx.Commit()
+}
+
+// DeleteExpired removes a batch of expired snippets along with their tags,
+// views and history. Their forks are kept.
+func (m *SnippetModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	// MySQL doesn't support LIMIT in IN subqueries so look the ids up first.
+	stmt := `SELECT id FROM snippets WHERE expires <= ? ORDER BY expires LIMIT ? FOR UPDATE`
+	ids, err := selectIDs(tx, stmt, before.UTC(), limit)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	_, err = tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id IN (`+placeholders+`)`, ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id IN (`+placeholders+`)`, ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_views WHERE snippet_id IN (`+placeholders+`)`, ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE snippets SET fork_of = NULL WHERE fork_of IN (`+placeholders+`)`, ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippets WHERE id IN (`+placeholders+`)`, ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(ids), tx.Commit()
+}
+
+// selectIDs runs a query returning a single id column
+func selectIDs(tx *sql.Tx, stmt string, args ...interface{}) ([]interface{}, error) {
+	rows, err := tx.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []interface{}{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Page returns a page of unexpired snippets around a cursor
+func (m *SnippetModel) Page(after, before *models.Cursor, limit int) (*models.SnippetPage, error) {
+	return m.page("", nil, after, before, limit)
+}
+
+// Search returns a page of the unexpired snippets matching every term of the
+// query, using the full-text index on title and content.
+func (m *SnippetModel) Search(query string, after, before *models.Cursor, limit int) (*models.SnippetPage, error) {
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return models.NewSnippetPage(nil, after, before, limit), nil
+	}
+
+	// In boolean mode +word* requires a word starting with the term. The terms
+	// only hold letters and digits so they can't contain operators.
+	words := make([]string, len(terms))
+	for i, t := range terms {
+		words[i] = "+" + t + "*"
+	}
+	args := []interface{}{strings.Join(words, " ")}
+
+	// Only the title of protected snippets is searched, the matches would give
+	// their content away otherwise. The full-text index covers both columns
+	// together so their titles are matched with LIKE.
+	titleCond := ""
+	for _, t := range terms {
+		titleCond += " AND title LIKE ?"
+		args = append(args, "%"+t+"%")
+	}
+
+	cond := `AND ((hashed_password IS NULL AND MATCH(title, content) AGAINST(? IN BOOLEAN MODE))
+					OR (hashed_password IS NOT NULL` + titleCond + `))`
+
+	return m.page(cond, args, after, before, limit)
+}
+
+// page returns a page of the unexpired public snippets matching an extra
+// condition
+func (m *SnippetModel) page(cond string, args []interface{}, after, before *models.Cursor, limit int) (*models.SnippetPage, error) {
+	// The row comparison is spelled out because MySQL can't use the index on
+	// created for (created, id) < (?, ?)
+	where, order := "", "DESC"
+	switch {
+	case after != nil:
+		where = "AND (created < ? OR (created = ? AND id < ?))"
+		args = append(args, after.Created.UTC(), after.Created.UTC(), after.ID)
+	case before != nil:
+		where, order = "AND (created > ? OR (created = ? AND id > ?))", "ASC"
+		args = append(args, before.Created.UTC(), before.Created.UTC(), before.ID)
+	}
+
+	stmt := fmt.Sprintf(`SELECT %s FROM snippets
+					WHERE (expires IS NULL OR expires > UTC_TIMESTAMP()) AND visibility = 'public' %s %s ORDER BY created %s, id %s LIMIT ?`, snippetColumns, cond, where, order, order)
+	snippets, err := m.query(stmt, append(args, limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewSnippetPage(snippets, after, before, limit), nil
+}
+
+// nullTime returns the value stored in a nullable time column, NULL for the
+// zero time
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
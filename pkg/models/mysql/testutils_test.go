@@ -0,0 +1,54 @@
+package mysql
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/eiliz/snippetbox/pkg/migrations"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// newTestDB connects to the database named by SNIPPETBOX_TEST_MYSQL_DSN,
+// skipping the test when it isn't set, e.g.
+// test_web:pass@/test_snippetbox?parseTime=true
+// Every migration is reverted and applied again, so the database must be one
+// that only the tests use.
+func newTestDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("SNIPPETBOX_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("SNIPPETBOX_TEST_MYSQL_DSN isn't set")
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	m, err := migrations.New(db, "mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Start from an empty schema, whatever the previous test left behind
+	for {
+		reverted, err := m.Down()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if reverted == nil {
+			break
+		}
+	}
+
+	if _, err = m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
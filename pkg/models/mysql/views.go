@@ -0,0 +1,72 @@
+package mysql
+
+import (
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+)
+
+// AddViews adds the counted views to their snippets in a single transaction
+func (m *SnippetModel) AddViews(counts []*models.ViewCount) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, c := range counts {
+		stmt := `UPDATE snippets SET views = views + ?,
+					last_viewed = CASE WHEN last_viewed IS NULL OR last_viewed < ? THEN ? ELSE last_viewed END
+					WHERE id = ?`
+		result, err := tx.Exec(stmt, c.Views, c.LastViewed, c.LastViewed, c.SnippetID)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		// The snippet was deleted since it was viewed
+		if n == 0 {
+			continue
+		}
+
+		stmt = `INSERT INTO snippet_views (snippet_id, day, views) VALUES (?, ?, ?)
+					ON DUPLICATE KEY UPDATE views = views + VALUES(views)`
+		if _, err = tx.Exec(stmt, c.SnippetID, c.Day, c.Views); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DailyViews returns the views of a snippet per day since the given one
+func (m *SnippetModel) DailyViews(id int, since time.Time) ([]*models.DailyViews, error) {
+	stmt := `SELECT day, views FROM snippet_views WHERE snippet_id = ? AND day >= ? ORDER BY day`
+	days := []*models.DailyViews{}
+
+	rows, err := m.DB.Query(stmt, id, since)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		d := &models.DailyViews{}
+		if err = rows.Scan(&d.Day, &d.Views); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return days, nil
+}
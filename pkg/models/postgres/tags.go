@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+	"github.com/lib/pq"
+)
+
+// insertTags attaches tags to a snippet, creating the ones that don't exist
+// yet.
+func insertTags(tx *sql.Tx, snippetID int, tags []string) error {
+	for _, tag := range tags {
+		_, err := tx.Exec(`INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, tag)
+		if err != nil {
+			return err
+		}
+
+		stmt := `INSERT INTO snippet_tags (snippet_id, tag_id) SELECT $1::integer, id FROM tags WHERE name = $2`
+		if _, err = tx.Exec(stmt, snippetID, tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadTags fills the tags of the snippets with a single query
+func (m *SnippetModel) loadTags(snippets []*models.Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	byID := map[int]*models.Snippet{}
+	ids := []int64{}
+	for _, s := range snippets {
+		byID[s.ID] = s
+		ids = append(ids, int64(s.ID))
+	}
+
+	stmt := `SELECT st.snippet_id, t.name FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+					WHERE st.snippet_id = ANY($1) ORDER BY t.name`
+
+	rows, err := m.DB.Query(stmt, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		if err = rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		byID[id].Tags = append(byID[id].Tags, name)
+	}
+
+	return rows.Err()
+}
+
+// Tagged returns a page of the unexpired snippets with the given tag
+func (m *SnippetModel) Tagged(tag string, after, before *models.Cursor, limit int) (*models.SnippetPage, error) {
+	cond := `AND id IN (SELECT st.snippet_id FROM snippet_tags st JOIN tags t ON t.id = st.tag_id WHERE t.name = $2)`
+	return m.page(cond, []interface{}{tag}, after, before, limit)
+}
@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+)
+
+// insertRevision copies the current title and content of a snippet into its
+// history as the next version, written by editorID. Two concurrent edits can
+// pick the same version, the unique constraint makes one of them fail.
+func insertRevision(tx *sql.Tx, snippetID, editorID int) error {
+	var version int
+	stmt := `SELECT COALESCE(MAX(version), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?`
+	if err := tx.QueryRow(stmt, snippetID).Scan(&version); err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_revisions (snippet_id, version, title, content, editor_id, created)
+					SELECT id, ?, title, content, NULLIF(?, 0), datetime('now') FROM snippets WHERE id = ?`
+	_, err := tx.Exec(stmt, version, editorID, snippetID)
+	return err
+}
+
+// Revisions returns every version of a snippet, the newest first
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
+	stmt := `SELECT r.snippet_id, r.version, r.title, r.content, COALESCE(r.editor_id, 0), COALESCE(u.name, ''), r.created
+					FROM snippet_revisions r LEFT JOIN users u ON u.id = r.editor_id
+					WHERE r.snippet_id = ? ORDER BY r.version DESC`
+	revisions := []*models.Revision{}
+
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		r := &models.Revision{}
+		err = rows.Scan(&r.SnippetID, &r.Version, &r.Title, &r.Content, &r.EditorID, &r.EditorName, &r.Created)
+
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Revision returns a single version of a snippet
+func (m *SnippetModel) Revision(id, version int) (*models.Revision, error) {
+	stmt := `SELECT r.snippet_id, r.version, r.title, r.content, COALESCE(r.editor_id, 0), COALESCE(u.name, ''), r.created
+					FROM snippet_revisions r LEFT JOIN users u ON u.id = r.editor_id
+					WHERE r.snippet_id = ? AND r.version = ?`
+
+	r := &models.Revision{}
+	err := m.DB.QueryRow(stmt, id, version).Scan(&r.SnippetID, &r.Version, &r.Title, &r.Content, &r.EditorID, &r.EditorName, &r.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,456 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The columns scanned by query
+const snippetColumns = `id, COALESCE(slug, ''), COALESCE(user_id, 0), COALESCE(fork_of, 0), title, content, language, detected_language, language_confidence,
+					visibility, format, burn_after_reading, hashed_password IS NOT NULL, views, last_viewed, created, expires`
+
+// SnippetModel is a type that wraps a sql.DB connection pool opened with the
+// sqlite3 driver
+type SnippetModel struct {
+	DB *sql.DB
+}
+
+// Insert inserts a new snippet into the db
+func (m *SnippetModel) Insert(s *models.Snippet) (int, error) {
+	// SQLite has no UTC_TIMESTAMP(), datetime('now') is always in UTC.
+	stmt := `INSERT INTO snippets (slug, user_id, title, content, language, detected_language, language_confidence, visibility, burn_after_reading, hashed_password, fork_of, format, created, expires)
+					VALUES(?, NULLIF(?, 0), ?, ?, ?, ?, ?, COALESCE(NULLIF(?, ''), 'public'), ?, NULLIF(?, ''), NULLIF(?, 0), COALESCE(NULLIF(?, ''), 'code'), datetime('now'), ?)`
+	// Slugs are random so the links to unlisted snippets can't be guessed
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+	s.Slug = slug
+
+	// The access password is hashed like the passwords of the users, no
+	// password is stored as NULL.
+	var hashedPassword string
+	if s.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(s.Password), 12)
+		if err != nil {
+			return 0, err
+		}
+		hashedPassword = string(hash)
+	}
+
+	// The snippet, its tags and its first revision are saved together or not
+	// at all.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.Exec(stmt, s.Slug, s.UserID, s.Title, s.Content, s.Language, s.DetectedLanguage, s.LanguageConfidence, s.Visibility, s.BurnAfterReading, hashedPassword, s.ForkOf, s.Format, nullTime(s.Expires))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = insertTags(tx, int(id), s.Tags); err != nil {
+		return 0, err
+	}
+
+	if err = insertRevision(tx, int(id), s.UserID); err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+// Get returns a specific snippet based on its id
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	return m.get("s.id = ?", id)
+}
+
+// GetBySlug returns a specific snippet based on its slug
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	return m.get("s.slug = ?", slug)
+}
+
+// selectSnippet selects the columns scanned by scanSnippet, which include the
+// name of the author and the current version
+const selectSnippet = `SELECT s.id, COALESCE(s.slug, ''), COALESCE(s.user_id, 0), COALESCE(s.fork_of, 0), COALESCE(u.name, ''), s.title, s.content, s.language,
+					s.detected_language, s.language_confidence, s.visibility, s.format, s.burn_after_reading, s.hashed_password IS NOT NULL, s.views, s.last_viewed, s.created, s.expires,
+					(SELECT COALESCE(MAX(r.version), 0) FROM snippet_revisions r WHERE r.snippet_id = s.id)
+					FROM snippets s LEFT JOIN users u ON u.id = s.user_id`
+
+// get returns the unexpired snippet matching a condition on its id or slug,
+// along with the name of its author and its tags
+func (m *SnippetModel) get(cond string, arg interface{}) (*models.Snippet, error) {
+	s, err := scanSnippet(m.DB.QueryRow(selectSnippet+` WHERE (s.expires IS NULL OR s.expires > datetime('now')) AND `+cond, arg))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = m.loadTags([]*models.Snippet{s}); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// scanSnippet scans a row selected by selectSnippet
+func scanSnippet(row *sql.Row) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	var lastViewed, expires sql.NullTime
+
+	err := row.Scan(&s.ID, &s.Slug, &s.UserID, &s.ForkOf, &s.AuthorName, &s.Title, &s.Content, &s.Language, &s.DetectedLanguage, &s.LanguageConfidence, &s.Visibility, &s.Format, &s.BurnAfterReading, &s.Protected, &s.Views, &lastViewed, &s.Created, &expires, &s.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	s.LastViewed, s.Expires = lastViewed.Time, expires.Time
+
+	return s, nil
+}
+
+// Consume returns an unexpired snippet and expires it in the same
+// transaction, so that a burn after reading snippet is only read once. It
+// returns ErrNoRecord when another request consumed the snippet first.
+func (m *SnippetModel) Consume(id int) (*models.Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	// Expiring the snippet before reading it locks its row, so a concurrent
+	// Consume waits for this one and then finds nothing left to update.
+	result, err := tx.Exec(`UPDATE snippets SET expires = datetime('now') WHERE id = ? AND (expires IS NULL OR expires > datetime('now'))`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		return nil, models.ErrNoRecord
+	}
+
+	s, err := scanSnippet(tx.QueryRow(selectSnippet+` WHERE s.id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if err = m.loadTags([]*models.Snippet{s}); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// CheckPassword returns ErrInvalidCredentials unless the password is the
+// access password of the snippet
+func (m *SnippetModel) CheckPassword(id int, password string) error {
+	var hashedPassword []byte
+	stmt := `SELECT hashed_password FROM snippets WHERE id = ? AND hashed_password IS NOT NULL`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrInvalidCredentials
+		}
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Latest returns the 10 most recently created public snippets
+func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+					WHERE (expires IS NULL OR expires > datetime('now')) AND visibility = 'public' ORDER BY created DESC, id DESC LIMIT 10`
+
+	return m.query(stmt)
+}
+
+// Forks returns the unexpired snippets forked from a snippet, the newest first
+func (m *SnippetModel) Forks(id int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+					WHERE (expires IS NULL OR expires > datetime('now')) AND fork_of = ? ORDER BY created DESC, id DESC`
+
+	return m.query(stmt, id)
+}
+
+// query runs a statement selecting the snippetColumns of snippets and returns
+// the rows with their tags
+func (m *SnippetModel) query(stmt string, args ...interface{}) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{}
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		s := &models.Snippet{}
+		var lastViewed, expires sql.NullTime
+		err = rows.Scan(&s.ID, &s.Slug, &s.UserID, &s.ForkOf, &s.Title, &s.Content, &s.Language, &s.DetectedLanguage, &s.LanguageConfidence, &s.Visibility, &s.Format, &s.BurnAfterReading, &s.Protected, &s.Views, &lastViewed, &s.Created, &expires)
+
+		if err != nil {
+			return nil, err
+		}
+		s.LastViewed, s.Expires = lastViewed.Time, expires.Time
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = m.loadTags(snippets); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Update changes the title, content, detected language and expiry of a
+// snippet and records the new version in its history
+func (m *SnippetModel) Update(s *models.Snippet, editorID int) error {
+	stmt := `UPDATE snippets SET title = ?, content = ?, detected_language = ?, language_confidence = ?, expires = ?
+					WHERE id = ? AND (expires IS NULL OR expires > datetime('now'))`
+	args := []interface{}{s.Title, s.Content, s.DetectedLanguage, s.LanguageConfidence, nullTime(s.Expires), s.ID}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	if err = insertRevision(tx, s.ID, editorID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Extend changes the expiry of an unexpired snippet, without recording a new
+// version in its history
+func (m *SnippetModel) Extend(id int, expires time.Time) error {
+	stmt := `UPDATE snippets SET expires = ? WHERE id = ? AND (expires IS NULL OR expires > datetime('now'))`
+	result, err := m.DB.Exec(stmt, nullTime(expires), id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// Delete removes a snippet, its tags, views and history before it expires
+func (m *SnippetModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_views WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	// The forks are kept, they only lose their parent
+	_, err = tx.Exec(`UPDATE snippets SET fork_of = NULL WHERE fork_of = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM snippets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return tx.Commit()
+}
+
+// DeleteExpired removes a batch of expired snippets along with their tags,
+// views and history. Their forks are kept.
+func (m *SnippetModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	expired := `SELECT id FROM snippets WHERE expires <= ? ORDER BY expires, id LIMIT ?`
+	_, err = tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id IN (`+expired+`)`, formatTime(before), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id IN (`+expired+`)`, formatTime(before), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_views WHERE snippet_id IN (`+expired+`)`, formatTime(before), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE snippets SET fork_of = NULL WHERE fork_of IN (`+expired+`)`, formatTime(before), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`DELETE FROM snippets WHERE id IN (`+expired+`)`, formatTime(before), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), tx.Commit()
+}
+
+// formatTime formats a time like datetime('now') does, so that it can be
+// compared with the values stored in DATETIME columns, which SQLite keeps as
+// plain text.
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// Page returns a page of unexpired snippets around a cursor
+func (m *SnippetModel) Page(after, before *models.Cursor, limit int) (*models.SnippetPage, error) {
+	return m.page("", nil, after, before, limit)
+}
+
+// Search returns a page of the unexpired snippets with every term of the query
+// in their title or content. SQLite has no full-text index here so it's a LIKE
+// per term, which is case insensitive for ASCII letters.
+func (m *SnippetModel) Search(query string, after, before *models.Cursor, limit int) (*models.SnippetPage, error) {
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return models.NewSnippetPage(nil, after, before, limit), nil
+	}
+
+	// Only the title of protected snippets is searched, the matches would give
+	// their content away otherwise.
+	cond := ""
+	args := []interface{}{}
+	for _, t := range terms {
+		cond += "AND (title LIKE ? OR (hashed_password IS NULL AND content LIKE ?)) "
+		args = append(args, "%"+t+"%", "%"+t+"%")
+	}
+
+	return m.page(cond, args, after, before, limit)
+}
+
+// page returns a page of the unexpired public snippets matching an extra
+// condition
+func (m *SnippetModel) page(cond string, args []interface{}, after, before *models.Cursor, limit int) (*models.SnippetPage, error) {
+	where, order := "", "DESC"
+	switch {
+	case after != nil:
+		where = "AND (created < ? OR (created = ? AND id < ?))"
+		args = append(args, formatTime(after.Created), formatTime(after.Created), after.ID)
+	case before != nil:
+		where, order = "AND (created > ? OR (created = ? AND id > ?))", "ASC"
+		args = append(args, formatTime(before.Created), formatTime(before.Created), before.ID)
+	}
+
+	stmt := fmt.Sprintf(`SELECT %s FROM snippets
+					WHERE (expires IS NULL OR expires > datetime('now')) AND visibility = 'public' %s %s ORDER BY created %s, id %s LIMIT ?`, snippetColumns, cond, where, order, order)
+	snippets, err := m.query(stmt, append(args, limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewSnippetPage(snippets, after, before, limit), nil
+}
+
+// nullTime returns the value stored in a nullable time column, formatted like
+// formatTime or NULL for the zero time
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return formatTime(t)
+}
@@ -0,0 +1,22 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) *storetest.Backend {
+		db := newTestDB(t)
+		return &storetest.Backend{
+			Snippets: &SnippetModel{DB: db},
+			Users:    &UserModel{DB: db},
+			SetCreated: func(created time.Time) error {
+				_, err := db.Exec(`UPDATE snippets SET created = ?`, formatTime(created))
+				return err
+			},
+		}
+	})
+}
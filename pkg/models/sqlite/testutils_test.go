@@ -0,0 +1,34 @@
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/eiliz/snippetbox/pkg/migrations"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestDB creates a fresh database file in a temporary directory and
+// migrates it
+func newTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := migrations.New(db, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	return db
+}
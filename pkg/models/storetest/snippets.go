@@ -0,0 +1,790 @@
+package storetest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+)
+
+func testInsert(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	expires := time.Now().AddDate(0, 0, 7)
+	id, err := m.Insert(&models.Snippet{UserID: 1, Title: "Haiku", Content: "Over the wintry forest...", Expires: expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ID != id || s.Title != "Haiku" || s.Content != "Over the wintry forest..." {
+		t.Errorf("unexpected snippet %+v", s)
+	}
+
+	if !closeTo(s.Expires, expires) {
+		t.Errorf("want expires %v, got %v", expires, s.Expires)
+	}
+
+	// Expired snippets are gone for good
+	expired, err := m.Insert(&models.Snippet{UserID: 1, Title: "Expired", Content: "Expired", Expires: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int{expired, expired + 1} {
+		if _, err := m.Get(id); !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("%d: want %v, got %v", id, models.ErrNoRecord, err)
+		}
+	}
+}
+
+func testLatest(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	for _, expires := range []time.Time{time.Now().Add(time.Hour), time.Now().Add(-time.Hour)} {
+		if _, err := m.Insert(&models.Snippet{UserID: 1, Title: "Title", Content: "Content", Expires: expires}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	latest, err := m.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(latest); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Errorf("want the unexpired snippets [2 1], got %v", got)
+	}
+}
+
+func testAuthor(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	s, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.UserID != 1 || s.AuthorName != "Alice Jones" {
+		t.Errorf("want snippet by user 1 Alice Jones, got %d %q", s.UserID, s.AuthorName)
+	}
+
+	// Snippets created without an account have no author
+	id, err := m.Insert(&models.Snippet{Title: "Anonymous", Content: "Anonymous", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err = m.Get(id); err != nil || s.UserID != 0 || s.AuthorName != "" {
+		t.Errorf("want a snippet without author, got %+v (%v)", s, err)
+	}
+}
+
+func testUpdate(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	before, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := &models.Snippet{ID: 1, Title: "Haiku", Content: "A frog jumps into the pond", Expires: before.Expires}
+	if err = m.Update(update, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Title != "Haiku" || s.Content != "A frog jumps into the pond" || !s.Expires.Equal(before.Expires) {
+		t.Errorf("unexpected snippet after update %+v", s)
+	}
+
+	if err = m.Update(&models.Snippet{ID: 1, Title: "Haiku", Content: "A frog jumps into the pond", Expires: time.Now().AddDate(0, 0, 1)}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ = m.Get(1); !s.Expires.Before(before.Expires) {
+		t.Errorf("want the expiry moved to tomorrow, got %v", s.Expires)
+	}
+
+	if err = m.Update(&models.Snippet{ID: 2, Title: "Haiku", Content: "Content"}, 1); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v, got %v", models.ErrNoRecord, err)
+	}
+}
+
+func testDelete(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	if err := m.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Get(1); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v, got %v", models.ErrNoRecord, err)
+	}
+
+	if err := m.Delete(1); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v, got %v", models.ErrNoRecord, err)
+	}
+}
+
+func testRevisions(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	if err := m.Update(&models.Snippet{ID: 1, Title: "Haiku", Content: "A frog jumps into the pond"}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first revision is the snippet as it was created
+	revisions, err := m.Revisions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revisions) != 2 || revisions[0].Version != 2 || revisions[0].EditorID != 1 || revisions[0].EditorName != "Alice Jones" {
+		t.Fatalf("want 2 revisions, the newest by Alice Jones first, got %d", len(revisions))
+	}
+
+	r, err := m.Revision(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Title != "An old silent pond" || r.Content != "An old silent pond..." {
+		t.Errorf("want the first version, got %q %q", r.Title, r.Content)
+	}
+
+	if r, err = m.Revision(1, 2); err != nil || r.Content != "A frog jumps into the pond" {
+		t.Errorf("want the updated version, got %+v (%v)", r, err)
+	}
+
+	if _, err = m.Revision(1, 3); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v, got %v", models.ErrNoRecord, err)
+	}
+
+	// The history goes with the snippet
+	if err = m.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if revisions, err = m.Revisions(1); err != nil || len(revisions) != 0 {
+		t.Errorf("want no revisions left, got %d (%v)", len(revisions), err)
+	}
+}
+
+func testDeleteExpired(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	expired := []int{}
+	for i := 3; i > 0; i-- {
+		id, err := m.Insert(&models.Snippet{UserID: 1, Title: "Expired", Content: "Expired", Expires: time.Now().Add(-time.Duration(i) * time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expired = append(expired, id)
+	}
+
+	n, err := m.DeleteExpired(time.Now(), 2)
+	if err != nil || n != 2 {
+		t.Fatalf("want 2 snippets deleted, got %d (%v)", n, err)
+	}
+
+	// The snippets that expired first go first
+	if revisions, _ := m.Revisions(expired[2]); len(revisions) != 1 {
+		t.Errorf("want the snippet that expired last kept for the next batch")
+	}
+
+	n, err = m.DeleteExpired(time.Now(), 2)
+	if err != nil || n != 1 {
+		t.Fatalf("want 1 snippet deleted, got %d (%v)", n, err)
+	}
+
+	for _, id := range expired {
+		if revisions, err := m.Revisions(id); err != nil || len(revisions) != 0 {
+			t.Errorf("%d: want the history deleted, got %d revisions (%v)", id, len(revisions), err)
+		}
+	}
+
+	if _, err = m.Get(1); err != nil {
+		t.Errorf("want the unexpired snippet left alone, got %v", err)
+	}
+}
+
+func testPage(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	for i := 0; i < 4; i++ {
+		if _, err := m.Insert(&models.Snippet{UserID: 1, Title: "Title", Content: "Content", Expires: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the ids tell apart the snippets created at the same time
+	if err := b.SetCreated(time.Now().UTC().Truncate(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := m.Page(nil, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(first.Snippets); !reflect.DeepEqual(got, []int{5, 4}) || first.Prev != nil || first.Next == nil {
+		t.Fatalf("want snippets [5 4] and only a next cursor, got %v", got)
+	}
+
+	second, err := m.Page(first.Next, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(second.Snippets); !reflect.DeepEqual(got, []int{3, 2}) || second.Prev == nil || second.Next == nil {
+		t.Fatalf("want snippets [3 2] and both cursors, got %v", got)
+	}
+
+	last, err := m.Page(second.Next, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(last.Snippets); !reflect.DeepEqual(got, []int{1}) || last.Next != nil {
+		t.Fatalf("want snippet [1] and no next cursor, got %v", got)
+	}
+
+	back, err := m.Page(nil, last.Prev, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(back.Snippets); !reflect.DeepEqual(got, []int{3, 2}) {
+		t.Errorf("want snippets [3 2] going back, got %v", got)
+	}
+}
+
+func testSearch(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	for _, title := range []string{"Silent night", "Loud morning"} {
+		if _, err := m.Insert(&models.Snippet{UserID: 1, Title: title, Content: "A frog jumps in", Expires: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantIDs []int
+	}{
+		{"Title and content", "silent", []int{2, 1}},
+		{"Every term", "SILENT frog", []int{2}},
+		{"Partial word", "morn", []int{3}},
+		{"No match", "winter", []int{}},
+		{"Only symbols", "%_%", []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := m.Search(tt.query, nil, nil, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := ids(page.Snippets); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("want %v, got %v", tt.wantIDs, got)
+			}
+		})
+	}
+}
+
+func testTags(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	id, err := m.Insert(&models.Snippet{UserID: 1, Title: "Haiku", Content: "Over the wintry forest...", Tags: []string{"winter", "haiku"}, Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing tags are reused
+	if _, err = m.Insert(&models.Snippet{UserID: 1, Title: "Another haiku", Content: "A frog jumps in", Tags: []string{"haiku"}, Expires: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"haiku", "winter"}; !reflect.DeepEqual(s.Tags, want) {
+		t.Errorf("want tags %q, got %q", want, s.Tags)
+	}
+
+	latest, err := m.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(latest) != 3 || len(latest[0].Tags) != 1 || latest[2].Tags != nil {
+		t.Errorf("want the tags in latest, and none for the untagged snippet")
+	}
+
+	for tag, wantIDs := range map[string][]int{"haiku": {3, 2}, "winter": {2}, "pond": {}} {
+		page, err := m.Tagged(tag, nil, nil, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ids(page.Snippets); !reflect.DeepEqual(got, wantIDs) {
+			t.Errorf("%s: want %v, got %v", tag, wantIDs, got)
+		}
+	}
+
+	// The tags go with the snippet
+	if err = m.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+
+	if page, err := m.Tagged("winter", nil, nil, 10); err != nil || len(page.Snippets) != 0 {
+		t.Errorf("want no snippet tagged winter left, got %v", err)
+	}
+}
+
+func testLanguage(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	id, err := m.Insert(&models.Snippet{UserID: 1, Title: "Hello", Content: "package main", Language: "go", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Language != "go" {
+		t.Errorf("want %q, got %q", "go", s.Language)
+	}
+
+	latest, err := m.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Snippets without a language are plain text
+	if len(latest) != 2 || latest[0].Language != "go" || latest[1].Language != "" {
+		t.Errorf("want the languages in latest")
+	}
+}
+
+func testDetectedLanguage(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	id, err := m.Insert(&models.Snippet{UserID: 1, Title: "Hello", Content: "package main", DetectedLanguage: "go", LanguageConfidence: 0.75, Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.DetectedLanguage != "go" || s.LanguageConfidence != 0.75 {
+		t.Errorf("want go detected at 0.75, got %q at %v", s.DetectedLanguage, s.LanguageConfidence)
+	}
+
+	// Edits detect the language again
+	s.Content = "key: value"
+	s.DetectedLanguage, s.LanguageConfidence = "yaml", 0.5
+	if err = m.Update(s, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err = m.Get(id); err != nil || s.DetectedLanguage != "yaml" || s.LanguageConfidence != 0.5 {
+		t.Errorf("want the detected language updated, got %+v (%v)", s, err)
+	}
+}
+
+func testVisibility(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	for _, visibility := range []string{models.VisibilityUnlisted, models.VisibilityPrivate, ""} {
+		s := &models.Snippet{UserID: 1, Title: "Pond " + visibility, Content: "A frog", Visibility: visibility, Expires: time.Now().Add(time.Hour)}
+		if _, err := m.Insert(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := m.Get(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Visibility != models.VisibilityPrivate {
+		t.Errorf("want private snippets returned by Get, got %q", s.Visibility)
+	}
+
+	if s, _ = m.Get(4); s.Visibility != models.VisibilityPublic {
+		t.Errorf("want public by default, got %q", s.Visibility)
+	}
+
+	latest, err := m.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(latest); !reflect.DeepEqual(got, []int{4, 1}) {
+		t.Errorf("want only the public snippets in latest, got %v", got)
+	}
+
+	for name, list := range map[string]func() (*models.SnippetPage, error){
+		"page":   func() (*models.SnippetPage, error) { return m.Page(nil, nil, 10) },
+		"search": func() (*models.SnippetPage, error) { return m.Search("pond", nil, nil, 10) },
+	} {
+		page, err := list()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ids(page.Snippets); !reflect.DeepEqual(got, []int{4, 1}) {
+			t.Errorf("%s: want only the public snippets, got %v", name, got)
+		}
+	}
+}
+
+func testGetBySlug(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	s := &models.Snippet{UserID: 1, Title: "Unlisted pond", Content: "A frog", Visibility: models.VisibilityUnlisted, Expires: time.Now().Add(time.Hour)}
+	id, err := m.Insert(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Slug) < 16 {
+		t.Fatalf("want a random slug set on insert, got %q", s.Slug)
+	}
+
+	got, err := m.GetBySlug(s.Slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.ID != id || got.Slug != s.Slug {
+		t.Errorf("want snippet %d with slug %q, got %d with %q", id, s.Slug, got.ID, got.Slug)
+	}
+
+	if _, err := m.GetBySlug("not-a-slug"); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v for an unknown slug, got %v", models.ErrNoRecord, err)
+	}
+}
+
+func testConsume(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	s, err := m.Consume(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Title != "An old silent pond" {
+		t.Errorf("want the consumed snippet returned, got %q", s.Title)
+	}
+
+	if _, err := m.Consume(1); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v consuming twice, got %v", models.ErrNoRecord, err)
+	}
+
+	if _, err := m.Get(1); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v getting a consumed snippet, got %v", models.ErrNoRecord, err)
+	}
+}
+
+func testCheckPassword(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	id, err := m.Insert(&models.Snippet{UserID: 1, Title: "Locked pond", Content: "A frog", Password: "open sesame", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.Protected {
+		t.Errorf("want the snippet protected")
+	}
+
+	tests := []struct {
+		name     string
+		id       int
+		password string
+		wantErr  error
+	}{
+		{"Valid", id, "open sesame", nil},
+		{"Wrong password", id, "sesame", models.ErrInvalidCredentials},
+		{"Unprotected snippet", 1, "", models.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.CheckPassword(tt.id, tt.password); !errors.Is(err, tt.wantErr) {
+				t.Errorf("want %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func testSearchProtected(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	if _, err := m.Insert(&models.Snippet{UserID: 1, Title: "Quiet dusk", Content: "A silent treasure", Password: "secret", Expires: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the title of a protected snippet is searched, matching its content
+	// would give it away
+	for query, wantIDs := range map[string][]int{"dusk": {2}, "treasure": {}, "silent": {1}} {
+		page, err := m.Search(query, nil, nil, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ids(page.Snippets); !reflect.DeepEqual(got, wantIDs) {
+			t.Errorf("%s: want %v, got %v", query, wantIDs, got)
+		}
+	}
+}
+
+func testNeverExpires(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	id, err := m.Insert(&models.Snippet{UserID: 1, Title: "Forever", Content: "Never expires"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil || !s.Expires.IsZero() {
+		t.Fatalf("want a snippet that never expires, got %v (%v)", s, err)
+	}
+
+	if _, err = m.DeleteExpired(time.Now().AddDate(100, 0, 0), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.Get(id); err != nil {
+		t.Errorf("want the snippet that never expires left alone, got %v", err)
+	}
+
+	if _, err = m.Get(1); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want the snippet that expires in a year purged, got %v", err)
+	}
+
+	// An edit can give it an expiry
+	s, _ = m.Get(id)
+	s.Expires = time.Now().Add(time.Hour)
+	if err = m.Update(s, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ = m.Get(id); s.Expires.IsZero() {
+		t.Errorf("want the snippet to expire after the edit")
+	}
+}
+
+func testExtend(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	expires := time.Now().AddDate(2, 0, 0)
+	if err := m.Extend(1, expires); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !closeTo(s.Expires, expires) {
+		t.Errorf("want expires %v, got %v", expires, s.Expires)
+	}
+
+	if err = m.Extend(1, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ = m.Get(1); !s.Expires.IsZero() {
+		t.Errorf("want the snippet to never expire, got %v", s.Expires)
+	}
+
+	// An extension isn't an edit
+	if revisions, _ := m.Revisions(1); len(revisions) != 1 {
+		t.Errorf("want no new revision, got %d revisions", len(revisions))
+	}
+
+	if err = m.Extend(2, expires); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v, got %v", models.ErrNoRecord, err)
+	}
+}
+
+func testAddViews(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	yesterday := today.AddDate(0, 0, -1)
+	counts := []*models.ViewCount{
+		{SnippetID: 1, Day: yesterday, Views: 2, LastViewed: yesterday.Add(time.Hour)},
+		{SnippetID: 1, Day: today, Views: 1, LastViewed: today.Add(time.Minute)},
+		// Deleted snippets are skipped
+		{SnippetID: 2, Day: today, Views: 5, LastViewed: today},
+	}
+
+	// The second time adds to the daily views already stored
+	for i := 0; i < 2; i++ {
+		if err := m.AddViews(counts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Views != 6 {
+		t.Errorf("want 6 views, got %d", s.Views)
+	}
+
+	if !s.LastViewed.Equal(today.Add(time.Minute)) {
+		t.Errorf("want last viewed %v, got %v", today.Add(time.Minute), s.LastViewed)
+	}
+
+	days, err := m.DailyViews(1, yesterday)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(days) != 2 || !days[0].Day.Equal(yesterday) || days[0].Views != 4 || days[1].Views != 2 {
+		t.Errorf("want 4 views yesterday and 2 today, got %d days", len(days))
+	}
+
+	if days, _ = m.DailyViews(1, today); len(days) != 1 {
+		t.Errorf("want only today, got %d days", len(days))
+	}
+
+	if days, _ = m.DailyViews(2, yesterday); len(days) != 0 {
+		t.Errorf("want no views for a missing snippet, got %d days", len(days))
+	}
+
+	// The views go with the snippet
+	if err = m.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if days, _ = m.DailyViews(1, yesterday); len(days) != 0 {
+		t.Errorf("want the views of the deleted snippet removed, got %d days", len(days))
+	}
+}
+
+func testForks(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	id, err := m.Insert(&models.Snippet{Title: "A fork", Content: "A fork", ForkOf: 1, Visibility: models.VisibilityPrivate, Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ForkOf != 1 {
+		t.Errorf("want fork of 1, got %d", s.ForkOf)
+	}
+
+	// Whatever their visibility
+	forks, err := m.Forks(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(forks); !reflect.DeepEqual(got, []int{id}) || forks[0].ForkOf != 1 {
+		t.Errorf("want the fork listed, got %v", got)
+	}
+
+	// The forks of a deleted snippet are kept without a parent
+	if err = m.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ = m.Get(id); s == nil || s.ForkOf != 0 {
+		t.Errorf("want the fork kept without a parent, got %+v", s)
+	}
+
+	// And so are the forks of a purged one
+	parent, err := m.Insert(&models.Snippet{Title: "Expiring", Content: "Expiring", Expires: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id, err = m.Insert(&models.Snippet{Title: "A fork", Content: "A fork", ForkOf: parent, Expires: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.DeleteExpired(time.Now(), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ = m.Get(id); s == nil || s.ForkOf != 0 {
+		t.Errorf("want the fork of the purged snippet kept without a parent, got %+v", s)
+	}
+}
+
+func testFormatAndVersion(t *testing.T, b *Backend) {
+	m := b.Snippets
+
+	s, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Snippets are code unless they say otherwise
+	if s.Format != models.FormatCode || s.Version != 1 {
+		t.Errorf("want a code snippet at version 1, got %q at %d", s.Format, s.Version)
+	}
+
+	id, err := m.Insert(&models.Snippet{Title: "Runbook", Content: "# Runbook", Format: models.FormatMarkdown, Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err = m.Get(id); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Content = "# Runbook v2"
+	if err = m.Update(s, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err = m.Get(id); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Format != models.FormatMarkdown || s.Version != 2 {
+		t.Errorf("want a Markdown snippet at version 2, got %q at %d", s.Format, s.Version)
+	}
+}
@@ -0,0 +1,91 @@
+// Package storetest holds the tests every storage backend must pass. Each
+// backend runs them from its own tests with Run, so the backends can't drift
+// apart.
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+)
+
+// Backend is an empty store of a storage backend
+type Backend struct {
+	Snippets models.SnippetStore
+	Users    models.UserStore
+	// SetCreated changes the creation time of every snippet, which the
+	// stores set themselves on Insert.
+	SetCreated func(created time.Time) error
+}
+
+// Run runs every test against a new Backend. The store is seeded with the
+// user alice@example.com (ID 1) named Alice Jones, whose password is
+// "validPa$$word", and a snippet of hers (ID 1) that expires in a year.
+func Run(t *testing.T, newBackend func(t *testing.T) *Backend) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, b *Backend)
+	}{
+		{"Insert", testInsert},
+		{"Latest", testLatest},
+		{"Author", testAuthor},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"Revisions", testRevisions},
+		{"DeleteExpired", testDeleteExpired},
+		{"Page", testPage},
+		{"Search", testSearch},
+		{"Tags", testTags},
+		{"Language", testLanguage},
+		{"DetectedLanguage", testDetectedLanguage},
+		{"Visibility", testVisibility},
+		{"GetBySlug", testGetBySlug},
+		{"Consume", testConsume},
+		{"CheckPassword", testCheckPassword},
+		{"SearchProtected", testSearchProtected},
+		{"NeverExpires", testNeverExpires},
+		{"Extend", testExtend},
+		{"AddViews", testAddViews},
+		{"Forks", testForks},
+		{"FormatAndVersion", testFormatAndVersion},
+		{"UserInsert", testUserInsert},
+		{"UserAuthenticate", testUserAuthenticate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t)
+			seed(t, b)
+			tt.test(t, b)
+		})
+	}
+}
+
+func seed(t *testing.T, b *Backend) {
+	if err := b.Users.Insert("Alice Jones", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &models.Snippet{UserID: 1, Title: "An old silent pond", Content: "An old silent pond...", Expires: time.Now().AddDate(1, 0, 0)}
+	if _, err := b.Snippets.Insert(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ids returns the ids of snippets, in order
+func ids(snippets []*models.Snippet) []int {
+	ids := []int{}
+	for _, s := range snippets {
+		ids = append(ids, s.ID)
+	}
+
+	return ids
+}
+
+// closeTo reports whether two times are within a second of each other, some
+// databases only store whole seconds
+func closeTo(got, want time.Time) bool {
+	d := got.Sub(want)
+	return d > -time.Second && d < time.Second
+}
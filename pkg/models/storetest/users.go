@@ -0,0 +1,45 @@
+package storetest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eiliz/snippetbox/pkg/models"
+)
+
+func testUserInsert(t *testing.T, b *Backend) {
+	m := b.Users
+
+	if err := m.Insert("Bob", "bob@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Emails are compared case insensitively
+	err := m.Insert("Alice", "Alice@example.com", "validPa$$word")
+	if !errors.Is(err, models.ErrDuplicateEmail) {
+		t.Errorf("want %v, got %v", models.ErrDuplicateEmail, err)
+	}
+}
+
+func testUserAuthenticate(t *testing.T, b *Backend) {
+	m := b.Users
+
+	id, err := m.Authenticate("alice@example.com", "validPa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.ID != 1 || u.Name != "Alice Jones" || !u.Active {
+		t.Errorf("unexpected user %+v", u)
+	}
+
+	_, err = m.Authenticate("alice@example.com", "wrongPa$$word")
+	if !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want %v, got %v", models.ErrInvalidCredentials, err)
+	}
+}